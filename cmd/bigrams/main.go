@@ -4,110 +4,224 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"runtime"
-	"sort"
-	"sync"
+	"strconv"
+	"strings"
 
 	fun "github.com/colinhb/penkata/pkg/myfuncs"
-	a "github.com/colinhb/penkata/pkg/mytypes"
 	penkata "github.com/colinhb/penkata/pkg/penkata"
 )
 
 func main() {
 	dirFlag := flag.String("d", "", "directory to process")
+	foldFlag := flag.Bool("i", false, "fold case (lowercase words before counting)")
+	dfFlag := flag.Bool("df", false, "also output the number of files each bigram occurs in")
+	perFileFlag := flag.String("p", "", "also write per-file counts to this file")
+	matrixFlag := flag.String("M", "", "also write per-file counts as a Matrix Market document-bigram matrix to <prefix>.mtx, with row and column indexes in <prefix>.rows.tsv and <prefix>.cols.tsv")
+	joinsFlag := flag.Bool("J", false, "report counts aggregated by cursive join type instead of bigram counts")
+	styleFlag := flag.String("style", "", "join style rules for -J (default italic)")
+	gutenbergFlag := flag.Bool("g", false, "strip Project Gutenberg headers, footers, license and transcriber's notes before counting")
+	binFlag := flag.Bool("bin", false, "write counts in the compact binary format")
+	modeFlag := flag.String("m", "tokens", "counting mode: tokens, types (each distinct word once), sqrt or log (dampened word frequency); only tokens with -approx, -p and -M")
+	approxFlag := flag.String("approx", "", "count approximately using a count-min sketch whose counters fit this memory budget (e.g. 64M)")
+	heavyFlag := flag.Int("hh", 10000, "number of most frequent bigrams to keep with -approx")
 	flag.Parse()
 	if *dirFlag == "" {
-		fmt.Fprintf(os.Stderr, "Usage: %s -d <directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s -d <directory> [-i] [-g] [-m <mode>] [-df] [-p <per-file-output>] [-M <matrix-prefix>] [-bin] [-approx <budget> [-hh <n>]] [-J [-style <join-style>]]\n", os.Args[0])
 		os.Exit(1)
 	}
 
-	errCh := make(chan error, 100)
-	defer close(errCh)
-
-	// Track if we encountered any errors
-	var errs []error
-	var errMu sync.Mutex
+	mode, err := penkata.ParseCountingMode(*modeFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if mode != penkata.CountTokens && (*approxFlag != "" || *perFileFlag != "" || *matrixFlag != "") {
+		fmt.Fprintln(os.Stderr, "Error: counting modes other than tokens (-m) cannot be combined with -approx, -p or -M")
+		os.Exit(1)
+	}
 
-	// Start error reporting in background
-	go func() {
-		for err := range errCh {
-			errMu.Lock()
-			errs = append(errs, err)
-			errMu.Unlock()
+	var sketch *penkata.CountSketch
+	if *approxFlag != "" {
+		if *dfFlag || *perFileFlag != "" || *matrixFlag != "" {
+			fmt.Fprintln(os.Stderr, "Error: approximate counting (-approx) cannot be combined with -df, -p or -M")
+			os.Exit(1)
+		}
+		budget, err := parseByteSize(*approxFlag)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-	}()
-
-	filesCh := make(chan string, 100)
-	go func() {
-		defer close(filesCh)
-		err := filepath.WalkDir(*dirFlag, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				errCh <- fmt.Errorf("access error for %s: %w", path, err)
-				return nil
-			}
-			if d.IsDir() {
-				return nil
-			}
-			if penkata.HasTextExtension(path) {
-				filesCh <- path
-			}
-			return nil
-		})
+		sketch, err = penkata.NewCountSketch(budget, *heavyFlag)
 		if err != nil {
-			errCh <- err
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-	}()
-
-	numWorkers := runtime.NumCPU()
-	var wg sync.WaitGroup
-
-	resultsCh := make(chan map[string]int, numWorkers)
-	for i := 0; i < numWorkers; i++ {
-		// This is our worker - captures filesCh and errCh from closure
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for path := range filesCh {
-				counts, err := penkata.CountBigramsInFile(path)
-				if err != nil {
-					errCh <- fmt.Errorf("processing %s: %w", path, err)
-					continue
-				}
-				resultsCh <- counts
-			}
-		}()
-	}
-
-	go func() {
-		wg.Wait()
-		close(resultsCh)
-	}()
-
-	// Merge results from all workers.
-	totals := make(map[string]int)
-	for res := range resultsCh {
-		fun.MergeMaps(totals, res)
-	}
-
-	// Convert map to sorted slice using the generic Tuple type
-	results := make([]a.Tuple[string, int], 0, len(totals))
-	for k, v := range totals {
-		results = append(results, a.Tuple[string, int]{First: k, Second: v})
-	}
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Second > results[j].Second
+	}
+
+	style := penkata.DefaultJoinStyle()
+	if *styleFlag != "" {
+		var err error
+		style, err = penkata.LoadJoinStyle(*styleFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading join style: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	normalizer := penkata.Normalizer{FoldCase: *foldFlag}
+	countOpts := penkata.CountOptions{
+		Normalizer: normalizer,
+		Gutenberg:  *gutenbergFlag,
+		Words:      mode != penkata.CountTokens,
+	}
+
+	// Merge each file's counts as it arrives, into the sketch if approximating
+	table := &penkata.BigramTable{
+		Header: penkata.NewTableHeader(*dirFlag, normalizer),
+		Counts: make(map[string]int),
+	}
+	if *dfFlag {
+		table.DocFreq = make(map[string]int)
+	}
+	table.Header.Counting = mode
+	table.Header.Gutenberg = *gutenbergFlag
+	words := make(map[string]int)
+	var perFile []*penkata.FileCounts
+	hasErrors := false
+	penkata.CountFiles(*dirFlag, countOpts, func(res *penkata.FileCounts) {
+		if *perFileFlag != "" || *matrixFlag != "" {
+			res.Path = penkata.RelativePath(*dirFlag, res.Path)
+			perFile = append(perFile, res)
+		}
+		if sketch != nil {
+			sketch.AddFile(res)
+			table.Header.Files++
+			table.Header.Tokens += res.Tokens
+			return
+		}
+		table.Add(res)
+		if mode != penkata.CountTokens {
+			fun.MergeMaps(words, res.Words)
+		}
+	}, func(err error) {
+		hasErrors = true
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	})
-	for _, r := range results {
-		fmt.Printf("%s\t%d\n", r.First, r.Second)
+	if mode != penkata.CountTokens {
+		// Replace token counts with counts over the corpus vocabulary
+		table.Counts = penkata.CountWordTypes(words, mode)
+		table.Total = 0
+		for _, count := range table.Counts {
+			table.Total += count
+		}
+	}
+	if sketch != nil {
+		table = sketch.Table(table.Header)
+		width, depth := sketch.Dimensions()
+		fmt.Fprintf(os.Stderr, "Approximate counts from a %dx%d count-min sketch (%d bytes): each count overestimates by at most %d (ε=%.2g of %d) with probability %g\n",
+			depth, width, sketch.Bytes(), sketch.ErrorBound(), sketch.Epsilon(), table.Total, penkata.DefaultSketchConfidence)
+	}
+
+	write := penkata.WriteBigramTable
+	if *binFlag {
+		write = penkata.WriteBinaryTable
+	}
+	if *joinsFlag {
+		printJoins(style, table)
+	} else if err := write(os.Stdout, table); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing counts: %v\n", err)
+		os.Exit(1)
 	}
 
-	errMu.Lock()
-	hasErrors := fun.Ternary(len(errs) > 0, true, false)
-	errMu.Unlock()
+	if *perFileFlag != "" {
+		if err := writeFileCounts(*perFileFlag, table.Header, perFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing per-file counts: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *matrixFlag != "" {
+		if err := writeMatrix(*matrixFlag, table.Header, perFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing document-bigram matrix: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	if hasErrors {
 		os.Exit(1)
 	}
 }
+
+// parseByteSize parses a memory size in bytes with an optional K, M or G suffix
+func parseByteSize(s string) (int, error) {
+	multiplier := 1
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid memory budget %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// writeFileCounts writes per-file counts to the named file
+func writeFileCounts(path string, header *penkata.TableHeader, files []*penkata.FileCounts) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := penkata.WriteFileCounts(file, header, files); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeMatrix writes per-file counts as a document-bigram matrix with its
+// row and column indexes, using prefix for the three file names
+func writeMatrix(prefix string, header *penkata.TableHeader, files []*penkata.FileCounts) error {
+	var outputs [3]*os.File
+	for i, suffix := range []string{".mtx", ".rows.tsv", ".cols.tsv"} {
+		file, err := os.Create(prefix + suffix)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		outputs[i] = file
+	}
+	if err := penkata.WriteMatrix(outputs[0], outputs[1], outputs[2], header, files); err != nil {
+		return err
+	}
+	for _, file := range outputs {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printJoins prints bigram counts aggregated by join type, in style rule order
+func printJoins(style *penkata.JoinStyle, table *penkata.BigramTable) {
+	joins := style.CountJoins(table.Counts)
+	total := 0
+	for _, count := range joins {
+		total += count
+	}
+
+	fmt.Println("join\tcount\tshare")
+	for _, t := range style.Types() {
+		share := 0.0
+		if total > 0 {
+			share = float64(joins[t]) / float64(total)
+		}
+		fmt.Printf("%s\t%d\t%.4f\n", t, joins[t], share)
+	}
+}
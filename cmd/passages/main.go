@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/colinhb/penkata/pkg/penkata"
 )
@@ -20,6 +22,9 @@ type flagValue struct {
 }
 
 func (v *flagValue) String() string {
+	if v.stringFunc == nil {
+		return "" // Zero value used by flag.PrintDefaults
+	}
 	return v.stringFunc()
 }
 
@@ -27,15 +32,80 @@ func (v *flagValue) Set(value string) error {
 	return v.setFunc(value)
 }
 
+// BigramFile is a bigram counts file with its coefficient in a blend
+type BigramFile struct {
+	Label       string // Files with the same label are blended, empty if unlabeled
+	Path        string
+	Coefficient float64
+}
+
+// parseBigramFile parses a "[label=][@coefficient:]path" bigram file
+// argument. The coefficient is marked with "@" so that paths such as
+// "2024:foo.tsv" are not mistaken for one.
+func parseBigramFile(value string) (BigramFile, error) {
+	var label string
+	if prefix, rest, ok := strings.Cut(value, "="); ok && prefix != "" && !strings.ContainsAny(prefix, `/\`) {
+		label, value = prefix, rest
+	}
+	if !strings.HasPrefix(value, "@") {
+		return BigramFile{Label: label, Path: value, Coefficient: 1}, nil
+	}
+	prefix, path, ok := strings.Cut(value[1:], ":")
+	if !ok {
+		return BigramFile{}, fmt.Errorf("blend coefficient in %q must be followed by ':' and a path", value)
+	}
+	coef, err := strconv.ParseFloat(prefix, 64)
+	if err != nil {
+		return BigramFile{}, fmt.Errorf("invalid blend coefficient %q", prefix)
+	}
+	if coef <= 0 {
+		return BigramFile{}, fmt.Errorf("coefficient for %s must be positive", path)
+	}
+	return BigramFile{Label: label, Path: path, Coefficient: coef}, nil
+}
+
+// groupBigramFiles groups bigram files by label, in order of first appearance
+func groupBigramFiles(files []BigramFile) [][]BigramFile {
+	var groups [][]BigramFile
+	index := make(map[string]int)
+	for _, file := range files {
+		i, ok := index[file.Label]
+		if !ok {
+			i = len(groups)
+			index[file.Label] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], file)
+	}
+	return groups
+}
+
 // Config holds program configuration from command-line flags
 type Config struct {
-	BigramFile       string                    // Path to bigram weights file
+	BigramFiles      []BigramFile              // Bigram count files, blended if they share a label
 	DirPath          string                    // Root directory to search
 	MaxChars         []int                     // Multiple max passage lengths
 	TopN             int                       // Number of passages to display
 	OutputFile       string                    // Path to output file (optional)
 	Verbose          bool                      // Whether to print intermediate results
 	WeightTransforms []penkata.WeightTransform // Types of transformations to apply to weights
+	FoldCase         bool                      // Whether to lowercase words before extracting bigrams
+	Strict           bool                      // Whether to require a valid, well-formed bigram file
+	Smoothing        penkata.Smoother          // Smoothing for rare and unseen bigrams (optional)
+	Alphabet         []rune                    // Target alphabet for smoothing (optional)
+	LeaveOneOutFile  string                    // Per-file counts for leave-one-out weights (optional)
+	OverlayFile      string                    // Per-bigram emphasis rules (optional)
+	JoinWeight       float64                   // Strength of the join type coverage reward (0 disables)
+	JoinStyleFile    string                    // Join style rules (optional, default italic)
+	FamilyWeight     float64                   // Strength of the letter family coverage reward (0 disables)
+	FamiliesFile     string                    // Letter family definitions (optional)
+	Legibility       bool                      // Whether to score windows by confusable coverage instead of bigram weights
+	ConfusablesFile  string                    // Confusables table (optional, default built-in)
+	KeyboardLayout   string                    // Keyboard layout for transition reporting (optional)
+	SaveFile         string                    // Where to save the counts computed from DirPath (optional)
+	Gutenberg        bool                      // Whether to read only the body of Project Gutenberg texts
+	Cite             bool                      // Whether to report the title, author, year and citation of each passage
+	CatalogFile      string                    // Project Gutenberg catalog for citations (optional)
 }
 
 // parseFlags processes command-line arguments and validates required parameters
@@ -43,10 +113,25 @@ func parseFlags() *Config {
 	config := &Config{}
 
 	// Create temporary slices for flag parsing
+	var bigramFiles []BigramFile
 	var sizes []int
 	var weightTransforms []penkata.WeightTransform
 
+	var smoothingSpec, alphabetSpec string
+
 	// Create wrapper values that implement flag.Value
+	fileValue := flagValue{
+		stringFunc: func() string { return fmt.Sprintf("%v", bigramFiles) },
+		setFunc: func(value string) error {
+			file, err := parseBigramFile(value)
+			if err != nil {
+				return err
+			}
+			bigramFiles = append(bigramFiles, file)
+			return nil
+		},
+	}
+
 	sizeValue := flagValue{
 		stringFunc: func() string { return fmt.Sprintf("%v", sizes) },
 		setFunc: func(value string) error {
@@ -62,36 +147,98 @@ func parseFlags() *Config {
 	transformValue := flagValue{
 		stringFunc: func() string { return fmt.Sprintf("%v", weightTransforms) },
 		setFunc: func(value string) error {
-			var transform penkata.WeightTransform
-			switch value {
-			case "raw":
-				transform = penkata.Raw
-			case "log1p":
-				transform = penkata.Log1p
-			case "normal":
-				transform = penkata.Normal
-			default:
-				return fmt.Errorf("invalid weight transformation type: %s. Must be one of: raw, log1p, normal", value)
+			transform, err := penkata.ParseTransform(value)
+			if err != nil {
+				return err
 			}
 			weightTransforms = append(weightTransforms, transform)
 			return nil
 		},
 	}
 
-	flag.StringVar(&config.BigramFile, "f", "sonnets-bigrams.tsv", "TSV file with bigram counts")
+	flag.Var(&fileValue, "f", "TSV file with bigram counts, optionally prefixed with a label and a blend coefficient (can be specified multiple times: files with the same label are blended, e.g. -f @0.7:gut.tsv -f @0.3:modern.tsv, and each label is scored separately, e.g. -f sonnets=sonnets.tsv -f gut=gut.tsv) (default: count the bigrams of -d before scoring)")
 	flag.StringVar(&config.DirPath, "d", "", "Directory to walk for text files")
 	flag.Var(&sizeValue, "c", "Maximum characters in passage (can be specified multiple times: -c 150 -c 300)")
 	flag.IntVar(&config.TopN, "n", 50, "Number of top-scoring passages to display per size")
 	flag.StringVar(&config.OutputFile, "o", "", "Output file for results (optional)")
 	flag.BoolVar(&config.Verbose, "v", false, "Enable verbose output of intermediate results")
-	flag.Var(&transformValue, "w", "Weight transformation type (raw, log1p, normal) (can be specified multiple times: -w log1p -w normal)")
+	flag.Var(&transformValue, "w", "Weight transformation, optionally with arguments and chained with + (e.g. log1p, pow:0.5, clip:1000+normal) (can be specified multiple times: -w log1p -w normal)")
+	flag.BoolVar(&config.FoldCase, "i", false, "Fold case (must match the case mode of the bigram file)")
+	flag.BoolVar(&config.Strict, "strict", false, "Reject bigram files without a header or with malformed lines")
+	flag.StringVar(&smoothingSpec, "s", "", "Smoothing for rare and unseen bigrams (addk:K, gt, interp:λ) (optional)")
+	flag.StringVar(&config.LeaveOneOutFile, "loo", "", "Per-file counts from bigrams -p; score each file with weights that exclude its own counts (optional)")
+	flag.StringVar(&config.OverlayFile, "e", "", "Overlay file of per-bigram emphasis rules applied after the transform (optional)")
+	flag.Float64Var(&config.JoinWeight, "j", 0, "Reward windows covering every cursive join type, with strength 0-1 (optional)")
+	flag.StringVar(&config.JoinStyleFile, "style", "", "Join style rules for -j (optional, default italic)")
+	flag.Float64Var(&config.FamilyWeight, "l", 0, "Reward windows covering every letter family and balancing ascenders with descenders, with strength 0-1 (optional)")
+	flag.StringVar(&config.FamiliesFile, "families", "", "Letter family definitions for -l (optional)")
+	flag.BoolVar(&config.Legibility, "L", false, "Score passages by coverage of confusable letter sequences and minimal-pair words instead of bigram weights")
+	flag.StringVar(&config.ConfusablesFile, "confusables", "", "Confusables table for -L (optional)")
+	flag.StringVar(&config.KeyboardLayout, "K", "", "Report key transitions on a keyboard layout: qwerty, dvorak, colemak or a layout file (optional)")
+	flag.BoolVar(&config.Gutenberg, "g", false, "Strip Project Gutenberg headers, footers, license and transcriber's notes before scoring")
+	flag.BoolVar(&config.Cite, "cite", false, "Add title, author, year, release year and citation columns from Project Gutenberg headers")
+	flag.StringVar(&config.CatalogFile, "catalog", "", "Project Gutenberg catalog (pg_catalog.csv) preferred over headers for -cite, implies -cite (optional)")
+	flag.StringVar(&config.SaveFile, "save", "", "Save the bigram counts computed from -d when no -f is given (optional)")
+	flag.StringVar(&alphabetSpec, "a", "", "Target alphabet for smoothing, with ranges (e.g. a-zA-Z) (default: characters in the bigram file)")
 	flag.Parse()
 
 	if config.DirPath == "" {
-		fmt.Fprintf(os.Stderr, "Usage: %s -d <directory> [-f <bigram-file>] [-c <size>] [-n <n>] [-o <output-file>] [-v] [-w <weight-transform>]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s -d <directory> [-f <bigram-file>] [-c <size>] [-n <n>] [-o <output-file>] [-v] [-w <weight-transform>] [-i] [-strict] [-s <smoothing>] [-a <alphabet>] [-loo <per-file-counts>] [-e <overlay>] [-j <strength>] [-style <join-style>] [-l <strength>] [-families <families>] [-L] [-confusables <confusables>] [-K <layout>] [-save <bigram-file>] [-g] [-cite] [-catalog <pg_catalog.csv>]\n", os.Args[0])
 		os.Exit(1)
 	}
 
+	if smoothingSpec != "" {
+		smoothing, err := penkata.ParseSmoothing(smoothingSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		config.Smoothing = smoothing
+	}
+	if alphabetSpec != "" {
+		alphabet, err := penkata.ParseAlphabet(alphabetSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		config.Alphabet = alphabet
+	}
+
+	// Without a bigram file, counts are computed from the scored directory
+	config.BigramFiles = bigramFiles
+	if len(bigramFiles) > 0 && config.SaveFile != "" {
+		fmt.Fprintln(os.Stderr, "Error: computed counts can only be saved (-save) when no bigram file (-f) is given")
+		os.Exit(1)
+	}
+	if len(config.BigramFiles) > 1 && config.LeaveOneOutFile != "" {
+		fmt.Fprintln(os.Stderr, "Error: leave-one-out weights (-loo) cannot be used with several bigram files")
+		os.Exit(1)
+	}
+	for _, file := range config.BigramFiles {
+		if (file.Label == "") != (config.BigramFiles[0].Label == "") {
+			fmt.Fprintln(os.Stderr, "Error: either all bigram files (-f) or none must have a label")
+			os.Exit(1)
+		}
+	}
+
+	if config.JoinWeight < 0 || config.JoinWeight > 1 {
+		fmt.Fprintln(os.Stderr, "Error: join coverage strength (-j) must be between 0 and 1")
+		os.Exit(1)
+	}
+	if config.FamilyWeight < 0 || config.FamilyWeight > 1 {
+		fmt.Fprintln(os.Stderr, "Error: letter family strength (-l) must be between 0 and 1")
+		os.Exit(1)
+	}
+
+	if config.Legibility && len(weightTransforms) > 0 {
+		fmt.Fprintln(os.Stderr, "Error: weight transformations (-w) do not apply to legibility scoring (-L)")
+		os.Exit(1)
+	}
+
+	if config.CatalogFile != "" {
+		config.Cite = true
+	}
+
 	// Default to 200 if no sizes specified
 	if len(sizes) == 0 {
 		config.MaxChars = []int{200}
@@ -147,20 +294,103 @@ func insertSorted(results []penkata.Passage, newPassage penkata.Passage, maxResu
 	return results
 }
 
-// getTransformName returns the string representation of a weight transform
-func getTransformName(transform penkata.WeightTransform) string {
-	switch transform {
-	case penkata.Log1p:
-		return "log1p"
-	case penkata.Normal:
-		return "normal"
-	default:
-		return "raw"
+// leaveOneOut derives window parameters whose weights exclude one file's counts.
+// Parameters sharing weights also share the derived weights.
+func leaveOneOut(paramsList []*penkata.WindowParams, counts *penkata.FileCounts) ([]*penkata.WindowParams, error) {
+	derived := make(map[*penkata.BigramWeights]*penkata.BigramWeights)
+	result := make([]*penkata.WindowParams, len(paramsList))
+	for i, params := range paramsList {
+		weights, ok := derived[params.Weights]
+		if !ok {
+			var err error
+			weights, err = params.Weights.LeaveOut(counts)
+			if err != nil {
+				return nil, err
+			}
+			derived[params.Weights] = weights
+		}
+		result[i] = penkata.NewWindowParams(weights, params.MaxChars, params.Scorers...)
+		result[i].Label = params.Label
+		result[i].Base = params.Base
+		result[i].Reporters = params.Reporters
 	}
+	return result, nil
+}
+
+// resultColumns returns the TSV column names for passages scored with params,
+// with citation columns if cite is set. Passages scored by a base scorer have
+// no transform column, since their score does not use the weights.
+func resultColumns(params *penkata.WindowParams, cite bool) []string {
+	columns := []string{"maxChar", "path", "score", "size"}
+	if params.Base == nil {
+		columns = append([]string{"transform"}, columns...)
+	}
+	if params.Label != "" {
+		columns = append([]string{"label"}, columns...)
+	}
+	if cite {
+		columns = append(columns, "title", "author", "year", "released", "citation")
+	}
+	if params.Weights.Overlay != nil {
+		columns = append(columns, "overrides")
+	}
+	if params.Base != nil {
+		columns = append(columns, params.Base.Columns()...)
+	}
+	for _, scorer := range params.Scorers {
+		columns = append(columns, scorer.Columns()...)
+	}
+	for _, reporter := range params.Reporters {
+		columns = append(columns, reporter.Columns()...)
+	}
+	return append(columns, "text")
+}
+
+// resultFields returns the TSV fields of a passage scored with params, with
+// citation fields from the metadata of its file if books is not nil
+func resultFields(params *penkata.WindowParams, p penkata.Passage, books map[string]*penkata.BookMetadata) []string {
+	fields := []string{
+		strconv.Itoa(params.MaxChars),
+		p.FilePath,
+		fmt.Sprintf("%.2f", p.Score()),
+		strconv.Itoa(p.Size()),
+	}
+	if params.Base == nil {
+		fields = append([]string{params.Weights.Transform.String()}, fields...)
+	}
+	if params.Label != "" {
+		fields = append([]string{params.Label}, fields...)
+	}
+	if books != nil {
+		book := books[p.FilePath]
+		year, released := "n.d.", ""
+		if book.Year() > 0 {
+			year = strconv.Itoa(book.Year())
+		}
+		if book.ReleaseYear() > 0 {
+			released = strconv.Itoa(book.ReleaseYear())
+		}
+		fields = append(fields, book.Title, book.Author(), year, released, book.Citation())
+	}
+	if overlay := params.Weights.Overlay; overlay != nil {
+		// Report which override rules touched the passage's bigrams
+		patterns := overlay.Influences(p.Bigrams())
+		fields = append(fields, strings.Join(patterns, ","))
+	}
+	if params.Base != nil {
+		fields = append(fields, params.Base.Report(&p.Window)...)
+	}
+	for _, scorer := range params.Scorers {
+		fields = append(fields, scorer.Report(&p.Window)...)
+	}
+	for _, reporter := range params.Reporters {
+		fields = append(fields, reporter.Report(&p.Window)...)
+	}
+	return append(fields, p.Text())
 }
 
 // printResults outputs all passages from the map to the specified writer
-func printResults(w io.Writer, bestPassagesByParams map[*penkata.WindowParams][]penkata.Passage, paramsList []*penkata.WindowParams, separateBySection bool) {
+func printResults(w io.Writer, bestPassagesByParams map[*penkata.WindowParams][]penkata.Passage, paramsList []*penkata.WindowParams, books map[string]*penkata.BookMetadata, separateBySection bool) {
 	if separateBySection {
 		// Print each section with its own header and results
 		for _, params := range paramsList {
@@ -169,43 +399,63 @@ func printResults(w io.Writer, bestPassagesByParams map[*penkata.WindowParams][]
 				continue
 			}
 
-			transformName := getTransformName(params.Weights.Transform)
-
-			// Print section header
+			// Print section header, noting smoothing since it applies to every section
+			description := describeParams(params)
+			if params.Weights.Smoothing != nil {
+				description += ", " + params.Weights.Smoothing.String() + " smoothing"
+			}
 			fmt.Fprintf(w, "\n=== Results for %d character passages (%s) ===\n",
-				params.MaxChars, transformName)
+				params.MaxChars, description)
 
 			// Print TSV header for this section
-			fmt.Fprintln(w, "transform\tmaxChar\tpath\tscore\tsize\ttext")
+			fmt.Fprintln(w, strings.Join(resultColumns(params, books != nil), "\t"))
 
 			// Print each passage
 			for _, p := range passages {
-				fmt.Fprintf(w, "%s\t%d\t%s\t%.2f\t%d\t%s\n",
-					transformName, params.MaxChars, p.FilePath, p.Score(), p.Size(), p.Text())
+				fmt.Fprintln(w, strings.Join(resultFields(params, p, books), "\t"))
 			}
 		}
 	} else {
-		// Print a single header followed by all results without section headers
-		fmt.Fprintln(w, "transform\tmaxChar\tpath\tscore\tsize\ttext")
+		// Print a single header followed by all results without section headers;
+		// all parameter sets share the same columns
+		fmt.Fprintln(w, strings.Join(resultColumns(paramsList[0], books != nil), "\t"))
 
 		// Process each parameter set in the original order
 		for _, params := range paramsList {
-			passages := bestPassagesByParams[params]
-			if len(passages) == 0 {
-				continue
-			}
-
-			transformName := getTransformName(params.Weights.Transform)
-
 			// Print each passage
-			for _, p := range passages {
-				fmt.Fprintf(w, "%s\t%d\t%s\t%.2f\t%d\t%s\n",
-					transformName, params.MaxChars, p.FilePath, p.Score(), p.Size(), p.Text())
+			for _, p := range bestPassagesByParams[params] {
+				fmt.Fprintln(w, strings.Join(resultFields(params, p, books), "\t"))
 			}
 		}
 	}
 }
 
+// saveTable writes a count table to the named file
+func saveTable(path string, table *penkata.BigramTable) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := penkata.WriteBigramTable(file, table); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// describeParams names the label and weight transform of a parameter set, or
+// the scoring for one with a base scorer
+func describeParams(params *penkata.WindowParams) string {
+	scoring := params.Weights.Transform.String()
+	if params.Base != nil {
+		scoring = "legibility"
+	}
+	if params.Label == "" {
+		return scoring
+	}
+	return params.Label + ", " + scoring
+}
+
 func main() {
 	// Parse command line arguments into a configuration structure
 	config := parseFlags()
@@ -222,21 +472,200 @@ func main() {
 		defer outputFile.Close()
 	}
 
-	// Create window parameters for each combination of size and weight transform
-	var paramsList []*penkata.WindowParams
+	// Load the emphasis overlay if specified
+	var overlay *penkata.Overlay
+	if config.OverlayFile != "" {
+		var err error
+		overlay, err = penkata.LoadOverlay(config.OverlayFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading overlay: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Read the bigram counts once and validate them against our normalization
+	loadOpts := penkata.LoadOptions{
+		Strict:     config.Strict,
+		Normalizer: penkata.Normalizer{FoldCase: config.FoldCase},
+		Gutenberg:  config.Gutenberg,
+		Warnings:   os.Stderr,
+	}
+	weightOpts := penkata.WeightOptions{
+		Normalizer: loadOpts.Normalizer,
+		Smoothing:  config.Smoothing,
+		Alphabet:   config.Alphabet,
+		Overlay:    overlay,
+	}
+	// Read the reference tables of transforms such as logodds
+	references := make(map[string]*penkata.BigramTable)
 	for _, transform := range config.WeightTransforms {
-		// Load bigram weights with the current transformation
-		weights, err := penkata.LoadBigramWeights(config.BigramFile, transform)
+		for _, path := range penkata.TransformReferences(transform) {
+			if _, ok := references[path]; !ok {
+				table, err := penkata.ReadBigramTable(path, loadOpts)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error loading reference bigrams: %v\n", err)
+					os.Exit(1)
+				}
+				references[path] = table
+			}
+			penkata.SetTransformReference(transform, path, references[path])
+		}
+	}
+
+	groups := groupBigramFiles(config.BigramFiles)
+	tables := make([]*penkata.BigramTable, len(groups))
+	labels := make([]string, len(groups))
+	for i, group := range groups {
+		labels[i] = group[0].Label
+		var parts []penkata.BlendPart
+		for _, file := range group {
+			table, err := penkata.ReadBigramTable(file.Path, loadOpts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading bigrams: %v\n", err)
+				os.Exit(1)
+			}
+			parts = append(parts, penkata.BlendPart{Name: file.Path, Table: table, Coefficient: file.Coefficient})
+		}
+
+		// Combine the files sharing a label into a single table
+		table, err := penkata.BlendTables(parts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error blending bigrams: %v\n", err)
+			os.Exit(1)
+		}
+		tables[i] = table
+	}
+
+	// Count the scored directory itself if no bigram file was given
+	if len(tables) == 0 {
+		errCount := 0
+		countOpts := penkata.CountOptions{Normalizer: loadOpts.Normalizer, Gutenberg: config.Gutenberg}
+		table := penkata.CountCorpus(config.DirPath, countOpts, nil, func(err error) {
+			errCount++
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		})
+		if table.Header.Files == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no files could be counted in %s\n", config.DirPath)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Counted %d bigrams in %d files\n", table.Total, table.Header.Files)
+		if errCount > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d errors while counting; the failed files are missing from the counts\n", errCount)
+		}
+		if config.SaveFile != "" {
+			if err := saveTable(config.SaveFile, table); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving bigram counts: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		tables, labels = []*penkata.BigramTable{table}, []string{""}
+	}
+
+	// Set up the base scorer that replaces the bigram score for legibility
+	// drills, the scorers that adjust the score of each window and the
+	// reporters that only add output columns
+	var base penkata.BaseScorer
+	var scorers []penkata.Scorer
+	var reporters []penkata.Reporter
+	if config.Legibility {
+		confusables := penkata.DefaultConfusables()
+		if config.ConfusablesFile != "" {
+			var err error
+			confusables, err = penkata.LoadConfusables(config.ConfusablesFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading confusables: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		base = &penkata.ConfusableScorer{Table: confusables}
+	}
+	if config.JoinWeight > 0 {
+		style := penkata.DefaultJoinStyle()
+		if config.JoinStyleFile != "" {
+			var err error
+			style, err = penkata.LoadJoinStyle(config.JoinStyleFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading join style: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		scorers = append(scorers, &penkata.JoinCoverageScorer{Style: style, Weight: config.JoinWeight})
+	}
+	if config.FamilyWeight > 0 {
+		families := penkata.DefaultFamilies()
+		if config.FamiliesFile != "" {
+			var err error
+			families, err = penkata.LoadFamilies(config.FamiliesFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading letter families: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		scorers = append(scorers, &penkata.FamilyScorer{Families: families, Weight: config.FamilyWeight})
+	}
+
+	if config.KeyboardLayout != "" {
+		layout, err := penkata.ParseKeyboardLayout(config.KeyboardLayout)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading bigrams with transform %v: %v\n", transform, err)
+			fmt.Fprintf(os.Stderr, "Error loading keyboard layout: %v\n", err)
 			os.Exit(1)
 		}
+		reporters = append(reporters, &penkata.KeyboardReporter{Layout: layout})
+	}
 
-		// Create parameters for each size with this weight transform
-		for _, size := range config.MaxChars {
-			params := penkata.NewWindowParams(weights, size)
-			// No longer need to create and store the ID
-			paramsList = append(paramsList, params)
+	var catalog penkata.Catalog
+	if config.CatalogFile != "" {
+		var err error
+		catalog, err = penkata.LoadCatalog(config.CatalogFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Create window parameters for each combination of label, weight transform and size
+	var paramsList []*penkata.WindowParams
+	for i, table := range tables {
+		for _, transform := range config.WeightTransforms {
+			// Derive bigram weights with the current transformation
+			weights, err := penkata.NewBigramWeights(table, transform, weightOpts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading bigrams: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Create parameters for each size with this weight transform
+			for _, size := range config.MaxChars {
+				params := penkata.NewWindowParams(weights, size, scorers...)
+				params.Label = labels[i]
+				params.Base = base
+				params.Reporters = reporters
+				paramsList = append(paramsList, params)
+			}
+		}
+	}
+
+	// Read per-file counts for leave-one-out weights, checking that they
+	// describe the same corpus as the bigram file
+	var perFile map[string]*penkata.FileCounts
+	if config.LeaveOneOutFile != "" {
+		var header *penkata.TableHeader
+		var err error
+		header, perFile, err = penkata.ReadFileCounts(config.LeaveOneOutFile, loadOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading per-file counts: %v\n", err)
+			os.Exit(1)
+		}
+		if table := tables[0]; table.Header != nil && filepath.Clean(table.Header.Corpus) != filepath.Clean(header.Corpus) {
+			fmt.Fprintf(os.Stderr, "Error: per-file counts are for corpus %s but the bigram file is for %s\n",
+				header.Corpus, table.Header.Corpus)
+			os.Exit(1)
+		}
+		if table := tables[0]; table.Header != nil {
+			if err := table.Header.CheckTokens(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: leave-one-out weights (-loo): %v\n", err)
+				os.Exit(1)
+			}
 		}
 	}
 
@@ -289,6 +718,9 @@ func main() {
 
 	var wg sync.WaitGroup
 
+	// Count the scored files with and without per-file counts for -loo
+	var matched, unmatched atomic.Int64
+
 	// Channel for workers to return passages back to main goroutine
 	resultsCh := make(chan []penkata.Passage, 4*numWorkers)
 
@@ -298,8 +730,24 @@ func main() {
 		go func() {
 			defer wg.Done()
 			for path := range filesCh {
+				// Use weights without this file's own counts if requested
+				fileParams := paramsList
+				counts, ok := perFile[penkata.RelativePath(config.DirPath, path)]
+				if perFile != nil && !ok {
+					unmatched.Add(1)
+				}
+				if ok {
+					matched.Add(1)
+					var err error
+					fileParams, err = leaveOneOut(paramsList, counts)
+					if err != nil {
+						errCh <- fmt.Errorf("processing %s: %w", path, err)
+						continue
+					}
+				}
+
 				// Find the best passages in each file (one for each size)
-				passages, err := penkata.FindBestPassagesInFile(path, paramsList)
+				passages, err := penkata.FindBestPassagesInFile(path, fileParams, config.Gutenberg)
 				if err != nil {
 					errCh <- fmt.Errorf("processing %s: %w", path, err)
 					continue
@@ -333,9 +781,10 @@ func main() {
 
 	// Process results as they arrive
 	for passages := range resultsCh {
-		for _, passage := range passages {
-			// Extract params from the Window embedded in Passage
-			params := passage.Window.Params()
+		for i, passage := range passages {
+			// Passages are returned in paramsList order; the window's own params
+			// may hold leave-one-out weights derived for its file
+			params := paramsList[i]
 
 			bestPassagesByParams[params] = insertSorted(bestPassagesByParams[params], passage, config.TopN)
 			statsByParams[params].Update(passage.Score())
@@ -344,15 +793,14 @@ func main() {
 			if bestPassagesByParams[params][0].FilePath == passage.FilePath {
 				// Print the new best passage
 				fmt.Fprintf(os.Stderr, "New best passage for %d characters (%s): %s\n",
-					params.MaxChars, getTransformName(params.Weights.Transform), passage.Text())
+					params.MaxChars, describeParams(params), passage.Text())
 			}
 
 			if config.Verbose {
 				fileCount := statsByParams[params].FilesProcessed
 				if fileCount%10 == 0 {
-					transformName := getTransformName(params.Weights.Transform)
 					fmt.Fprintf(os.Stderr, "MaxChars %d; Weight %s: %s\n",
-						params.MaxChars, transformName, statsByParams[params])
+						params.MaxChars, describeParams(params), statsByParams[params])
 				}
 			}
 		}
@@ -362,13 +810,44 @@ func main() {
 	if config.Verbose {
 		fmt.Fprintln(os.Stderr, "\nFinal Statistics:")
 		for _, params := range paramsList {
-			transformName := getTransformName(params.Weights.Transform)
 			fmt.Fprintf(os.Stderr, "MaxChars %d; Weight %s: %s\n",
-				params.MaxChars, transformName, statsByParams[params])
+				params.MaxChars, describeParams(params), statsByParams[params])
 		}
 		fmt.Fprintln(os.Stderr)
 	}
 
+	// Leave-one-out weights only apply to files found in the per-file counts,
+	// whose paths are relative to the directory counted with bigrams -p
+	if perFile != nil {
+		if matched.Load() == 0 {
+			fmt.Fprintf(os.Stderr, "Error: none of the scored files are in the per-file counts of %s; run passages -d with the directory counted by bigrams -p\n",
+				config.LeaveOneOutFile)
+			os.Exit(1)
+		}
+		if n := unmatched.Load(); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d scored files are not in the per-file counts and were scored with the full weights\n", n)
+		}
+	}
+
+	// Look up the metadata of the files with top passages for citations
+	var books map[string]*penkata.BookMetadata
+	if config.Cite {
+		books = make(map[string]*penkata.BookMetadata)
+		for _, passages := range bestPassagesByParams {
+			for _, p := range passages {
+				if _, ok := books[p.FilePath]; ok {
+					continue
+				}
+				book, err := penkata.LookupBook(p.FilePath, catalog)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading metadata: %v\n", err)
+					book = &penkata.BookMetadata{}
+				}
+				books[p.FilePath] = book
+			}
+		}
+	}
+
 	// Output results to the appropriate destination
 	outputDest := os.Stdout
 	if outputFile != nil {
@@ -376,7 +855,7 @@ func main() {
 	}
 
 	// Print all results with a single function call
-	printResults(outputDest, bestPassagesByParams, paramsList, outputFile == nil)
+	printResults(outputDest, bestPassagesByParams, paramsList, books, outputFile == nil)
 
 	// Exit with error status if any errors occurred during processing
 	errMu.Lock()
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	fun "github.com/colinhb/penkata/pkg/myfuncs"
+	penkata "github.com/colinhb/penkata/pkg/penkata"
+)
+
+func main() {
+	dirFlag := flag.String("d", "", "directory to count and summarize")
+	tableFlag := flag.String("f", "", "bigram file to summarize instead of a directory")
+	foldFlag := flag.Bool("i", false, "fold case (lowercase words before counting) with -d")
+	gutenbergFlag := flag.Bool("g", false, "strip Project Gutenberg headers, footers, license and transcriber's notes with -d")
+	alphabetFlag := flag.String("a", "a-z", "target alphabet for coverage, with ranges (e.g. a-zA-Z)")
+	charsFlag := flag.Int("n", 40, "number of most frequent characters to list (0 for all)")
+	strictFlag := flag.Bool("strict", false, "report malformed lines in the bigram file")
+	flag.Parse()
+	if (*dirFlag == "") == (*tableFlag == "") {
+		fmt.Fprintf(os.Stderr, "Usage: %s (-d <directory> [-i] [-g] | -f <bigram-file> [-strict]) [-a <alphabet>] [-n <chars>]\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	alphabet, err := penkata.ParseAlphabet(*alphabetFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var stats *penkata.CorpusStats
+	errCount := 0
+	source := *tableFlag
+	if *dirFlag != "" {
+		source = *dirFlag
+		// Merge the words of every file for the number of types and keep only
+		// each file's size; bigram counts are merged into the table
+		words := make(map[string]int)
+		var fileTokens []int
+		opts := penkata.CountOptions{Normalizer: penkata.Normalizer{FoldCase: *foldFlag}, Gutenberg: *gutenbergFlag, Words: true}
+		table := penkata.CountCorpus(*dirFlag, opts, func(counts *penkata.FileCounts) {
+			fun.MergeMaps(words, counts.Words)
+			fileTokens = append(fileTokens, counts.Tokens)
+		}, func(err error) {
+			errCount++
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		})
+		if len(fileTokens) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no files could be counted in %s\n", *dirFlag)
+			os.Exit(1)
+		}
+		stats = penkata.NewCorpusStats(table, words, fileTokens)
+	} else {
+		table, err := penkata.ParseBigramTable(*tableFlag, *strictFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading bigram file: %v\n", err)
+			os.Exit(1)
+		}
+		stats = penkata.NewCorpusStats(table, nil, nil)
+	}
+
+	printStats(source, stats, alphabet, *charsFlag)
+	if errCount > 0 {
+		os.Exit(1)
+	}
+}
+
+// printStats writes the report as metric<TAB>value lines followed by the
+// character distribution
+func printStats(source string, s *penkata.CorpusStats, alphabet []rune, chars int) {
+	unknown := func(n int, known bool) string {
+		if !known {
+			return "-"
+		}
+		return fmt.Sprint(n)
+	}
+
+	fmt.Printf("# %s\n", source)
+	fmt.Println("metric\tvalue")
+	fmt.Printf("files\t%s\n", unknown(s.Files, s.Files > 0))
+	fmt.Printf("tokens\t%s\n", unknown(s.Tokens, s.Tokens > 0))
+	fmt.Printf("types\t%s\n", unknown(s.Types, s.FileTokens != nil))
+	if s.FileTokens != nil && s.Tokens > 0 {
+		fmt.Printf("type-token-ratio\t%.4f\n", float64(s.Types)/float64(s.Tokens))
+	}
+	fmt.Printf("characters\t%d\n", len(s.Chars))
+	fmt.Printf("char-entropy\t%.4f\n", s.CharEntropy)
+	fmt.Printf("bigrams\t%d\n", s.Bigrams)
+	fmt.Printf("bigram-total\t%d\n", s.Total)
+	fmt.Printf("bigram-entropy\t%.4f\n", s.BigramEntropy)
+	fmt.Printf("zipf-exponent\t%.4f\n", s.ZipfExponent)
+	fmt.Printf("zipf-r2\t%.4f\n", s.ZipfR2)
+	for _, mass := range []float64{0.5, 0.9, 0.99} {
+		fmt.Printf("bigrams-for-%g%%\t%d\n", mass*100, s.BigramsForMass(mass))
+	}
+
+	c := s.Coverage(alphabet)
+	fmt.Printf("alphabet-letters\t%d/%d\n", c.Letters, len(alphabet))
+	if len(c.Missing) > 0 {
+		fmt.Printf("alphabet-missing\t%s\n", string(c.Missing))
+	}
+	fmt.Printf("alphabet-bigrams\t%d/%d\n", c.Bigrams, c.Possible)
+	fmt.Printf("alphabet-mass\t%.4f\n", c.Mass)
+
+	if s.FileTokens != nil {
+		total := 0
+		for _, n := range s.FileTokens {
+			total += n
+		}
+		fmt.Printf("file-tokens-min\t%d\n", s.FileTokenQuantile(0))
+		fmt.Printf("file-tokens-p25\t%d\n", s.FileTokenQuantile(0.25))
+		fmt.Printf("file-tokens-median\t%d\n", s.FileTokenQuantile(0.5))
+		fmt.Printf("file-tokens-p75\t%d\n", s.FileTokenQuantile(0.75))
+		fmt.Printf("file-tokens-max\t%d\n", s.FileTokenQuantile(1))
+		fmt.Printf("file-tokens-mean\t%.1f\n", float64(total)/float64(max(len(s.FileTokens), 1)))
+	}
+
+	// Character distribution, most frequent first
+	runes := make([]rune, 0, len(s.Chars))
+	charTotal := 0
+	for r, count := range s.Chars {
+		runes = append(runes, r)
+		charTotal += count
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		if s.Chars[runes[i]] != s.Chars[runes[j]] {
+			return s.Chars[runes[i]] > s.Chars[runes[j]]
+		}
+		return runes[i] < runes[j]
+	})
+	if chars > 0 {
+		runes = runes[:min(chars, len(runes))]
+	}
+	fmt.Println("\n=== characters ===")
+	fmt.Println("char\tcount\tshare")
+	for _, r := range runes {
+		fmt.Printf("%c\t%d\t%.4f\n", r, s.Chars[r], float64(s.Chars[r])/float64(charTotal))
+	}
+}
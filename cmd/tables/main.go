@@ -0,0 +1,363 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	penkata "github.com/colinhb/penkata/pkg/penkata"
+)
+
+// command is a subcommand of tables
+type command struct {
+	usage string // Arguments, shown in the usage message
+	run   func(args []string) error
+}
+
+// commands holds the subcommands by name
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"compare": {"[-k <top-k>] [-n <rows>] [-strict] [-o <output-file>] <file> <other-file>", runCompare},
+		"convert": {"[-to bin|tsv] [-strict] [-o <output-file>] <file>", runConvert},
+		"filter":  {"[-a <alphabet>] [-min <count>] [-to bin|tsv] [-strict] [-o <output-file>] <file>", runFilter},
+		"import":  {"[-format norvig|csv|json] [-b <boundary-chars>] [-i] [-col <n>] [-strict] [-o <output-file>] <file>", runImport},
+		"scale":   {"-k <factor> [-to bin|tsv] [-strict] [-o <output-file>] <file>", runScale},
+		"sub":     {"[-to bin|tsv] [-strict] [-o <output-file>] <file> <subtracted-file>", runSub},
+		"sum":     {"[-to bin|tsv] [-strict] [-o <output-file>] <file>...", runSum},
+		"top":     {"(-k <n> | -mass <fraction>) [-to bin|tsv] [-strict] [-o <output-file>] <file>", runTop},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the subcommands and their arguments
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s %s %s\n", os.Args[0], name, commands[name].usage)
+	}
+}
+
+// newFlagSet creates the flag set of a subcommand
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n", os.Args[0], name, commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runImport converts an external letter-pair frequency table into a count table
+func runImport(args []string) error {
+	fs := newFlagSet("import")
+	format := fs.String("format", "", "input format: norvig, csv or json (default: detected from the extension)")
+	boundary := fs.String("b", penkata.DefaultImportBoundary, "characters that mark a word boundary in the input")
+	fold := fs.Bool("i", false, "fold case (lowercase pairs and sum their counts)")
+	column := fs.Int("col", 2, "1-based column of the counts in CSV files")
+	strict := fs.Bool("strict", false, "reject entries that are not bigrams instead of skipping them")
+	output := fs.String("o", "", "output file (default: stdout)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	table, skipped, err := penkata.ImportBigramTable(fs.Arg(0), penkata.ImportOptions{
+		Format:   *format,
+		Boundary: *boundary,
+		FoldCase: *fold,
+		Column:   *column,
+		Strict:   *strict,
+	})
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d entries that are not bigrams\n", skipped)
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		return penkata.WriteBigramTable(w, table)
+	})
+}
+
+// runConvert converts a count table between the TSV and binary formats
+func runConvert(args []string) error {
+	fs := newFlagSet("convert")
+	to := fs.String("to", "bin", "output format: bin or tsv")
+	strict := fs.Bool("strict", false, "report malformed lines in TSV input")
+	output := fs.String("o", "", "output file (default: stdout)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	write, err := tableWriter(*to)
+	if err != nil {
+		return err
+	}
+	table, err := penkata.ParseBigramTable(fs.Arg(0), *strict)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		return write(w, table)
+	})
+}
+
+// tableFlags holds the input and output flags shared by the table algebra subcommands
+type tableFlags struct {
+	strict *bool
+	to     *string
+	output *string
+}
+
+// addTableFlags defines the shared flags on a subcommand's flag set
+func addTableFlags(fs *flag.FlagSet) *tableFlags {
+	return &tableFlags{
+		strict: fs.Bool("strict", false, "report malformed lines in TSV input"),
+		to:     fs.String("to", "tsv", "output format: bin or tsv"),
+		output: fs.String("o", "", "output file (default: stdout)"),
+	}
+}
+
+// read reads a count table in either format
+func (f *tableFlags) read(path string) (*penkata.BigramTable, error) {
+	return penkata.ParseBigramTable(path, *f.strict)
+}
+
+// write writes a count table in the requested format
+func (f *tableFlags) write(table *penkata.BigramTable) error {
+	write, err := tableWriter(*f.to)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*f.output, func(w io.Writer) error {
+		return write(w, table)
+	})
+}
+
+// parseArgs parses a subcommand's arguments, exiting with its usage unless
+// the number of positional arguments is between min and max (0 for no limit)
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) {
+	fs.Parse(args)
+	if fs.NArg() < min || (max > 0 && fs.NArg() > max) {
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+// runSum adds the counts of several tables, e.g. of separately counted shards
+func runSum(args []string) error {
+	fs := newFlagSet("sum")
+	tf := addTableFlags(fs)
+	parseArgs(fs, args, 1, 0)
+
+	var tables []*penkata.BigramTable
+	for _, path := range fs.Args() {
+		table, err := tf.read(path)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+	sum, err := penkata.SumTables(fs.Args(), tables)
+	if err != nil {
+		return err
+	}
+	return tf.write(sum)
+}
+
+// runSub subtracts the counts of one table from another
+func runSub(args []string) error {
+	fs := newFlagSet("sub")
+	tf := addTableFlags(fs)
+	parseArgs(fs, args, 2, 2)
+
+	table, err := tf.read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	other, err := tf.read(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	diff, err := table.Subtract(other)
+	if err != nil {
+		return err
+	}
+	return tf.write(diff)
+}
+
+// runScale multiplies every count of a table by a factor
+func runScale(args []string) error {
+	fs := newFlagSet("scale")
+	factor := fs.Float64("k", 0, "factor to multiply counts by")
+	tf := addTableFlags(fs)
+	parseArgs(fs, args, 1, 1)
+	if *factor <= 0 {
+		return fmt.Errorf("scale factor (-k) must be positive")
+	}
+
+	table, err := tf.read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return tf.write(table.Scale(*factor))
+}
+
+// runFilter keeps the bigrams over an alphabet with a minimum count
+func runFilter(args []string) error {
+	fs := newFlagSet("filter")
+	alphabetSpec := fs.String("a", "", "keep bigrams of these characters, with ranges (e.g. a-zA-Z) (default: all)")
+	minCount := fs.Int("min", 0, "keep bigrams counted at least this often")
+	tf := addTableFlags(fs)
+	parseArgs(fs, args, 1, 1)
+
+	var alphabet []rune
+	if *alphabetSpec != "" {
+		var err error
+		alphabet, err = penkata.ParseAlphabet(*alphabetSpec)
+		if err != nil {
+			return err
+		}
+	}
+
+	table, err := tf.read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return tf.write(table.Filter(alphabet, *minCount))
+}
+
+// runTop keeps the most frequent bigrams of a table
+func runTop(args []string) error {
+	fs := newFlagSet("top")
+	k := fs.Int("k", 0, "keep this many of the most frequent bigrams")
+	mass := fs.Float64("mass", 0, "keep the most frequent bigrams holding this fraction of the total count")
+	tf := addTableFlags(fs)
+	parseArgs(fs, args, 1, 1)
+	if (*k > 0) == (*mass > 0) {
+		return fmt.Errorf("exactly one of -k and -mass must be given")
+	}
+	if *mass > 1 {
+		return fmt.Errorf("mass (-mass) must be between 0 and 1")
+	}
+
+	table, err := tf.read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *k > 0 {
+		return tf.write(table.Top(*k))
+	}
+	return tf.write(table.TopMass(*mass))
+}
+
+// runCompare reports how far apart the bigram distributions of two tables are
+func runCompare(args []string) error {
+	fs := newFlagSet("compare")
+	topK := fs.Int("k", 50, "number of most frequent bigrams compared for overlap")
+	rows := fs.Int("n", 20, "number of over- and under-represented bigrams to list")
+	strict := fs.Bool("strict", false, "report malformed lines in TSV input")
+	output := fs.String("o", "", "output file (default: stdout)")
+	parseArgs(fs, args, 2, 2)
+	if *topK < 0 {
+		return fmt.Errorf("number of bigrams for overlap (-k) must not be negative")
+	}
+	if *rows < 0 {
+		return fmt.Errorf("number of bigrams to list (-n) must not be negative")
+	}
+
+	var tables [2]*penkata.BigramTable
+	for i := range tables {
+		var err error
+		if tables[i], err = penkata.ParseBigramTable(fs.Arg(i), *strict); err != nil {
+			return err
+		}
+	}
+	c, err := penkata.CompareTables(tables[0], tables[1], *topK, *rows)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		fmt.Fprintf(w, "# P: %s\n# Q: %s\n", fs.Arg(0), fs.Arg(1))
+		fmt.Fprintln(w, "metric\tvalue")
+		fmt.Fprintf(w, "kl(P||Q)\t%.4f\n", c.KL)
+		fmt.Fprintf(w, "kl(Q||P)\t%.4f\n", c.KLReverse)
+		fmt.Fprintf(w, "js\t%.4f\n", c.JS)
+		fmt.Fprintf(w, "cosine\t%.4f\n", c.Cosine)
+		fmt.Fprintf(w, "spearman\t%.4f\n", c.Spearman)
+		fmt.Fprintf(w, "top%d-overlap\t%.4f\n", c.TopK, float64(c.TopOverlap)/float64(max(c.TopK, 1)))
+
+		printRatios(w, "over-represented in P", c.Over)
+		printRatios(w, "under-represented in P", c.Under)
+		return nil
+	})
+}
+
+// printRatios prints a section of bigrams with their relative frequencies in both tables
+func printRatios(w io.Writer, title string, ratios []penkata.BigramRatio) {
+	fmt.Fprintf(w, "\n=== %s ===\n", title)
+	fmt.Fprintln(w, "bigram\tp\tq\tlog2-ratio")
+	for _, r := range ratios {
+		fmt.Fprintf(w, "%s\t%.6f\t%.6f\t%.3f\n", r.Bigram, r.P, r.Q, r.LogRatio)
+	}
+}
+
+// tableWriter returns the function that writes tables in the named format
+func tableWriter(format string) (func(io.Writer, *penkata.BigramTable) error, error) {
+	switch format {
+	case "tsv":
+		return penkata.WriteBigramTable, nil
+	case "bin":
+		return penkata.WriteBinaryTable, nil
+	default:
+		return nil, fmt.Errorf("unknown table format %q (expected bin or tsv)", format)
+	}
+}
+
+// writeOutput calls write with the named file, or stdout if the name is empty
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
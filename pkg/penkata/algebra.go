@@ -0,0 +1,225 @@
+package penkata
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	fun "github.com/colinhb/penkata/pkg/myfuncs"
+)
+
+// SumTables adds the counts of several tables, e.g. of corpus shards counted
+// separately. Document frequencies are summed if every table has them, which
+// is exact for shards with disjoint files. Only tables counting tokens can be
+// summed.
+func SumTables(names []string, tables []*BigramTable) (*BigramTable, error) {
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables to sum")
+	}
+
+	result := &BigramTable{Counts: make(map[string]int)}
+	result.DocFreq = make(map[string]int)
+	for i, t := range tables {
+		if err := checkCompatible(tables[0], t); err != nil {
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+		if err := checkTokens(t); err != nil {
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+		fun.MergeMaps(result.Counts, t.Counts)
+		result.Total += t.Total
+		if t.DocFreq == nil {
+			result.DocFreq = nil
+		} else if result.DocFreq != nil {
+			fun.MergeMaps(result.DocFreq, t.DocFreq)
+		}
+	}
+
+	result.Header = derivedHeader(tables, "sum("+strings.Join(names, ", ")+")")
+	if result.Header != nil {
+		for _, t := range tables {
+			result.Header.Files = addCounts(result.Header.Files, t.Header.Files)
+			result.Header.Tokens = addCounts(result.Header.Tokens, t.Header.Tokens)
+		}
+	}
+
+	return result, nil
+}
+
+// Subtract returns a copy of the table with another table's counts removed.
+// Counts are clamped at zero, and bigrams left without counts are dropped.
+// Only tables counting tokens can be subtracted.
+func (t *BigramTable) Subtract(other *BigramTable) (*BigramTable, error) {
+	if err := checkCompatible(t, other); err != nil {
+		return nil, err
+	}
+	if err := checkTokens(t); err != nil {
+		return nil, err
+	}
+
+	result := &BigramTable{Counts: make(map[string]int, len(t.Counts))}
+	if t.DocFreq != nil && other.DocFreq != nil {
+		result.DocFreq = make(map[string]int, len(t.DocFreq))
+	}
+	for bigram, count := range t.Counts {
+		count = max(count-other.Counts[bigram], 0)
+		if count == 0 {
+			continue
+		}
+		result.Counts[bigram] = count
+		result.Total += count
+		if result.DocFreq != nil {
+			result.DocFreq[bigram] = max(t.DocFreq[bigram]-other.DocFreq[bigram], 1)
+		}
+	}
+
+	if t.Header != nil && other.Header != nil {
+		result.Header = derivedHeader([]*BigramTable{t, other}, t.Header.Corpus+" - "+other.Header.Corpus)
+		result.Header.Files = subtractCounts(t.Header.Files, other.Header.Files)
+		result.Header.Tokens = subtractCounts(t.Header.Tokens, other.Header.Tokens)
+	}
+
+	return result, nil
+}
+
+// Scale returns a copy of the table with every count multiplied by factor and
+// rounded. Bigrams whose counts round to zero are dropped. Document
+// frequencies are kept unchanged, and the error bound is scaled as well.
+func (t *BigramTable) Scale(factor float64) *BigramTable {
+	result := t.derive(func(bigram string, count int) int {
+		return int(math.Round(float64(count) * factor))
+	})
+	if result.Header != nil && result.Header.ErrorBound > 0 {
+		result.Header.ErrorBound = 0
+		result.Header.addErrorBound(t.Header, factor)
+	}
+	return result
+}
+
+// Filter returns a copy of the table with only the bigrams whose characters
+// all belong to the alphabet (word boundaries always do) and whose count is
+// at least minCount. A nil alphabet keeps every character.
+func (t *BigramTable) Filter(alphabet []rune, minCount int) *BigramTable {
+	allowed := make(map[rune]bool, len(alphabet)+1)
+	for _, r := range alphabet {
+		allowed[r] = true
+	}
+	allowed['_'] = true
+
+	return t.derive(func(bigram string, count int) int {
+		if count < minCount {
+			return 0
+		}
+		if alphabet != nil {
+			for _, r := range bigram {
+				if !allowed[r] {
+					return 0
+				}
+			}
+		}
+		return count
+	})
+}
+
+// Top returns a copy of the table with only its k most frequent bigrams
+func (t *BigramTable) Top(k int) *BigramTable {
+	keep := make(map[string]bool, k)
+	for _, bigram := range t.SortedBigrams()[:min(k, len(t.Counts))] {
+		keep[bigram] = true
+	}
+	return t.derive(func(bigram string, count int) int {
+		return fun.Ternary(keep[bigram], count, 0)
+	})
+}
+
+// TopMass returns a copy of the table with only its most frequent bigrams
+// that together hold at least the given fraction of the total count
+func (t *BigramTable) TopMass(mass float64) *BigramTable {
+	keep := make(map[string]bool)
+	cumulative := 0
+	for _, bigram := range t.SortedBigrams() {
+		if float64(cumulative) >= mass*float64(t.Total) {
+			break
+		}
+		keep[bigram] = true
+		cumulative += t.Counts[bigram]
+	}
+	return t.derive(func(bigram string, count int) int {
+		return fun.Ternary(keep[bigram], count, 0)
+	})
+}
+
+// derive returns a copy of the table with each count replaced by f(bigram,
+// count), dropping bigrams whose new count is not positive
+func (t *BigramTable) derive(f func(bigram string, count int) int) *BigramTable {
+	result := &BigramTable{Counts: make(map[string]int)}
+	if t.DocFreq != nil {
+		result.DocFreq = make(map[string]int)
+	}
+	for bigram, count := range t.Counts {
+		count = f(bigram, count)
+		if count <= 0 {
+			continue
+		}
+		result.Counts[bigram] = count
+		result.Total += count
+		if result.DocFreq != nil {
+			result.DocFreq[bigram] = t.DocFreq[bigram]
+		}
+	}
+	if t.Header != nil {
+		header := *t.Header
+		header.Created = time.Now().UTC()
+		result.Header = &header
+	}
+	return result
+}
+
+// checkCompatible reports an error if two tables with headers were counted
+// with different normalizations
+func checkCompatible(a, b *BigramTable) error {
+	if a.Header == nil || b.Header == nil {
+		return nil
+	}
+	return a.Header.compatible(b.Header)
+}
+
+// checkTokens reports an error if a table with a header did not count tokens
+func checkTokens(t *BigramTable) error {
+	if t.Header == nil {
+		return nil
+	}
+	return t.Header.CheckTokens()
+}
+
+// derivedHeader returns a header for a table computed from others, with zero
+// file and token totals, or nil if any of them lacks a header. The
+// normalization is imported if any of them was imported, Project Gutenberg
+// texts were stripped only if they were for all of them, and the error bounds
+// of approximate tables add up.
+func derivedHeader(tables []*BigramTable, corpus string) *TableHeader {
+	for _, t := range tables {
+		if t.Header == nil {
+			return nil
+		}
+	}
+	h := tables[0].Header
+	header := &TableHeader{
+		Version:       TableFormatVersion,
+		Corpus:        corpus,
+		Normalization: h.Normalization,
+		CaseMode:      h.CaseMode,
+		Counting:      h.Counting,
+		Gutenberg:     h.Gutenberg,
+		Created:       time.Now().UTC(),
+	}
+	for _, t := range tables {
+		if t.Header.Normalization == ImportedNormalization {
+			header.Normalization = ImportedNormalization
+		}
+		header.Gutenberg = header.Gutenberg && t.Header.Gutenberg
+		header.addErrorBound(t.Header, 1)
+	}
+	return header
+}
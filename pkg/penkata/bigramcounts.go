@@ -3,45 +3,212 @@ package penkata
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// CountingMode determines how much each word contributes to bigram counts
+type CountingMode string
 
-	fun "github.com/colinhb/penkata/pkg/myfuncs"
+// Counting modes, from counting every occurrence to counting every word type once
+const (
+	CountTokens CountingMode = "tokens" // Every occurrence of a word counts
+	CountTypes  CountingMode = "types"  // Every distinct word counts once
+	CountSqrt   CountingMode = "sqrt"   // Words count the square root of their frequency
+	CountLog    CountingMode = "log"    // Words count 1 + the logarithm of their frequency
 )
 
-// countBigrams processes a file and extracts bigrams.
-func CountBigramsInFile(path string) (map[string]int, error) {
-	file, err := os.Open(path)
+// ParseCountingMode returns the counting mode with the given name
+func ParseCountingMode(name string) (CountingMode, error) {
+	switch mode := CountingMode(name); mode {
+	case CountTokens, CountTypes, CountSqrt, CountLog:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown counting mode %q (expected tokens, types, sqrt or log)", name)
+}
+
+// weight returns the contribution of a word occurring freq times
+func (m CountingMode) weight(freq int) float64 {
+	switch m {
+	case CountTypes:
+		return 1
+	case CountSqrt:
+		return math.Sqrt(float64(freq))
+	case CountLog:
+		return 1 + math.Log(float64(freq))
+	default:
+		return float64(freq)
+	}
+}
+
+// CountWordTypes derives bigram counts from word frequencies, with each word's
+// bigrams weighted according to the mode. Counts are rounded, so bigrams
+// whose total weight rounds to zero are dropped.
+func CountWordTypes(words map[string]int, mode CountingMode) map[string]int {
+	weights := make(map[string]float64)
+	for word, freq := range words {
+		w := mode.weight(freq)
+		for _, bg := range wordBigrams(word) {
+			weights[bg] += w
+		}
+	}
+
+	counts := make(map[string]int, len(weights))
+	for bg, w := range weights {
+		if c := int(math.Round(w)); c > 0 {
+			counts[bg] = c
+		}
+	}
+	return counts
+}
+
+// FileCounts holds the bigram counts extracted from a single file. Its counts
+// are always token counts; other counting modes are derived from the word
+// frequencies of the whole corpus with CountWordTypes, so per-file counts,
+// document-bigram matrices and sketches, which add up FileCounts, only
+// support CountTokens.
+type FileCounts struct {
+	Path   string         // Source file path
+	Counts map[string]int // Bigram counts
+	Words  map[string]int // Frequencies of the normalized words, nil unless CountOptions.Words is set
+	Tokens int            // Number of words that produced bigrams
+}
+
+// CountOptions controls how the bigrams of a file are counted
+type CountOptions struct {
+	Normalizer Normalizer // Normalization applied to each word
+	Gutenberg  bool       // Count only the body of Project Gutenberg texts
+	Words      bool       // Also record word frequencies, e.g. for counting modes other than tokens
+}
+
+// CountBigramsInFile processes a file and extracts bigrams. With
+// opts.Gutenberg set, only the body of a Project Gutenberg text is counted.
+func CountBigramsInFile(path string, opts CountOptions) (*FileCounts, error) {
+	file, err := OpenText(path, opts.Gutenberg)
 	if err != nil {
 		return nil, fmt.Errorf("opening file %s: %w", path, err)
 	}
 	defer file.Close()
 
-	counts := make(map[string]int)
+	result := &FileCounts{
+		Path:   path,
+		Counts: make(map[string]int),
+	}
+	if opts.Words {
+		result.Words = make(map[string]int)
+	}
 	scanner := bufio.NewScanner(file)
 	scanner.Split(bufio.ScanWords)
 
 	for scanner.Scan() {
-		fun.MergeMaps(counts, countBigramsInWord(scanner.Text()))
+		word := opts.Normalizer.Normalize(scanner.Text())
+		bigrams := wordBigrams(word)
+		if len(bigrams) == 0 {
+			continue
+		}
+		for _, bg := range bigrams {
+			result.Counts[bg]++
+		}
+		if result.Words != nil {
+			result.Words[word]++
+		}
+		result.Tokens++
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("scanning file %s: %w", path, err)
 	}
 
-	return counts, nil
+	return result, nil
 }
 
-// countBigramsInWord counts bigrams in a word.
-func countBigramsInWord(word string) map[string]int {
-	counts := make(map[string]int)
+// CountFiles counts the bigrams of every text file under dir with a pool of
+// workers and passes each file's counts to each as they arrive, so that
+// callers can merge them without all of them being kept. Files that cannot be
+// read are passed to report and skipped. Both functions are called from the
+// calling goroutine.
+func CountFiles(dir string, opts CountOptions, each func(*FileCounts), report func(error)) {
+	numWorkers := runtime.NumCPU()
+	errCh := make(chan error, 2*numWorkers)
 
-	// Get all bigrams from this word
-	bigrams := extractBigramsFromWord(word)
+	filesCh := make(chan string, 10*numWorkers)
+	go func() {
+		defer close(filesCh)
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				errCh <- fmt.Errorf("access error for %s: %w", path, err)
+				return nil
+			}
+			if !d.IsDir() && HasTextExtension(path) {
+				filesCh <- path
+			}
+			return nil
+		})
+		if err != nil {
+			errCh <- err
+		}
+	}()
 
-	// Count each bigram
-	for _, bg := range bigrams {
-		counts[bg]++
+	var wg sync.WaitGroup
+	resultsCh := make(chan *FileCounts, numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range filesCh {
+				counts, err := CountBigramsInFile(path, opts)
+				if err != nil {
+					errCh <- fmt.Errorf("counting %s: %w", path, err)
+					continue
+				}
+				resultsCh <- counts
+			}
+		}()
 	}
 
-	return counts
+	// The walk ends before the workers, so no errors follow their results
+	go func() {
+		wg.Wait()
+		close(resultsCh)
+		close(errCh)
+	}()
+
+	for resultsCh != nil || errCh != nil {
+		select {
+		case counts, ok := <-resultsCh:
+			if !ok {
+				resultsCh = nil
+				continue
+			}
+			each(counts)
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			report(err)
+		}
+	}
+}
+
+// CountCorpus counts the bigrams of every text file under dir with CountFiles,
+// merging each file's counts into a table with document frequencies. Each
+// file's counts are also passed to each, if not nil.
+func CountCorpus(dir string, opts CountOptions, each func(*FileCounts), report func(error)) *BigramTable {
+	table := &BigramTable{
+		Header:  NewTableHeader(dir, opts.Normalizer),
+		Counts:  make(map[string]int),
+		DocFreq: make(map[string]int),
+	}
+	table.Header.Gutenberg = opts.Gutenberg
+	CountFiles(dir, opts, func(counts *FileCounts) {
+		table.Add(counts)
+		if each != nil {
+			each(counts)
+		}
+	}, report)
+	return table
 }
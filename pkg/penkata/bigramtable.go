@@ -0,0 +1,457 @@
+package penkata
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TableFormatVersion is the count table format version written by WriteBigramTable
+const TableFormatVersion = 1
+
+// tableMagic is the header key on the first line of every versioned count table
+const tableMagic = "penkata-bigrams"
+
+// ImportedNormalization is the normalization recorded for tables imported
+// from external sources, whose word normalization is unknown
+const ImportedNormalization = "imported"
+
+// UnknownCount is recorded for a number of files or tokens that is unknown,
+// and written as "unknown"
+const UnknownCount = -1
+
+// unknownCountValue is how UnknownCount is written in headers
+const unknownCountValue = "unknown"
+
+// ErrImportedNormalization is returned by Check for imported tables, whose
+// normalization cannot be checked. Callers may treat it as a warning.
+var ErrImportedNormalization = errors.New("table was imported and its normalization is unknown")
+
+// TableHeader describes how a bigram count table was produced
+type TableHeader struct {
+	Version       int          // Format version
+	Corpus        string       // Path of the counted corpus
+	Files         int          // Number of files counted, or UnknownCount
+	Tokens        int          // Number of words counted, or UnknownCount
+	Normalization string       // Word normalization rules (see Normalizer.Name)
+	CaseMode      string       // Case handling (see Normalizer.CaseMode)
+	Counting      CountingMode // How much each word contributed, empty for tables written before it was recorded
+	Gutenberg     bool         // Whether only the bodies of Project Gutenberg texts were counted
+	Created       time.Time    // When the table was written
+	ErrorBound    int          // Largest overestimate of any count in approximate tables, 0 if exact
+	Confidence    float64      // Probability that ErrorBound holds
+}
+
+// NewTableHeader creates a header for a table counted with the given normalizer
+func NewTableHeader(corpus string, normalizer Normalizer) *TableHeader {
+	return &TableHeader{
+		Version:       TableFormatVersion,
+		Corpus:        corpus,
+		Normalization: normalizer.Name(),
+		CaseMode:      normalizer.CaseMode(),
+		Counting:      CountTokens,
+		Created:       time.Now().UTC(),
+	}
+}
+
+// Check reports whether a table with this header can be scored with the given
+// normalizer. For imported tables only the case mode is checked, and
+// ErrImportedNormalization is returned if it matches.
+func (h *TableHeader) Check(normalizer Normalizer) error {
+	if h.Version > TableFormatVersion {
+		return fmt.Errorf("unsupported table format version %d (newest supported is %d)", h.Version, TableFormatVersion)
+	}
+	if h.Normalization == ImportedNormalization {
+		if h.CaseMode != normalizer.CaseMode() {
+			return fmt.Errorf("table case mode %q does not match scorer case mode %q", h.CaseMode, normalizer.CaseMode())
+		}
+		return ErrImportedNormalization
+	}
+	if h.Normalization != normalizer.Name() {
+		return fmt.Errorf("table normalization %q does not match scorer normalization %q", h.Normalization, normalizer.Name())
+	}
+	if h.CaseMode != normalizer.CaseMode() {
+		return fmt.Errorf("table case mode %q does not match scorer case mode %q", h.CaseMode, normalizer.CaseMode())
+	}
+	return nil
+}
+
+// CountingMode returns how the table was counted; tables written before the
+// mode was recorded counted tokens
+func (h *TableHeader) CountingMode() CountingMode {
+	if h.Counting == "" {
+		return CountTokens
+	}
+	return h.Counting
+}
+
+// CheckTokens reports an error if the table did not count tokens. Counts by
+// the other modes weight words by their frequency in the whole corpus, so
+// they cannot be added to or subtracted from other counts.
+func (h *TableHeader) CheckTokens() error {
+	if mode := h.CountingMode(); mode != CountTokens {
+		return fmt.Errorf("table counts %s, but only token counts can be added or subtracted", mode)
+	}
+	return nil
+}
+
+// gutenbergMode describes whether Project Gutenberg texts are stripped
+func gutenbergMode(gutenberg bool) string {
+	if gutenberg {
+		return "with Project Gutenberg headers stripped (-g)"
+	}
+	return "whole (without -g)"
+}
+
+// compatible reports whether two tables were counted with the same
+// normalization and counting mode. Imported tables are compatible with any
+// normalization of the same case mode.
+func (h *TableHeader) compatible(other *TableHeader) error {
+	imported := h.Normalization == ImportedNormalization || other.Normalization == ImportedNormalization
+	if (h.Normalization != other.Normalization && !imported) || h.CaseMode != other.CaseMode {
+		return fmt.Errorf("normalization %s (%s case) does not match %s (%s case)",
+			h.Normalization, h.CaseMode, other.Normalization, other.CaseMode)
+	}
+	if h.CountingMode() != other.CountingMode() {
+		return fmt.Errorf("a table counting %s does not match one counting %s", h.CountingMode(), other.CountingMode())
+	}
+	return nil
+}
+
+// fields returns the header as ordered key/value pairs, starting with the
+// magic key of the file format and its version
+func (h *TableHeader) fields(magic string) [][2]string {
+	fields := [][2]string{
+		{magic, strconv.Itoa(h.Version)},
+		{"corpus", h.Corpus},
+		{"files", formatCount(h.Files)},
+		{"tokens", formatCount(h.Tokens)},
+		{"normalization", h.Normalization},
+		{"case", h.CaseMode},
+	}
+	if h.Counting != "" {
+		fields = append(fields, [2]string{"counting", string(h.Counting)})
+	}
+	if h.Gutenberg {
+		fields = append(fields, [2]string{"gutenberg", "true"})
+	}
+	fields = append(fields, [2]string{"created", h.Created.Format(time.RFC3339)})
+	if h.ErrorBound > 0 {
+		fields = append(fields,
+			[2]string{"error-bound", strconv.Itoa(h.ErrorBound)},
+			[2]string{"confidence", strconv.FormatFloat(h.Confidence, 'g', -1, 64)})
+	}
+	return fields
+}
+
+// set assigns a header field parsed from a table file
+func (h *TableHeader) set(key, value string) error {
+	var err error
+	switch key {
+	case tableMagic, fileCountsMagic:
+		h.Version, err = strconv.Atoi(value)
+	case "corpus":
+		h.Corpus = value
+	case "files":
+		h.Files, err = parseCount(value)
+	case "tokens":
+		h.Tokens, err = parseCount(value)
+	case "normalization":
+		h.Normalization = value
+	case "case":
+		h.CaseMode = value
+	case "counting":
+		h.Counting, err = ParseCountingMode(value)
+	case "gutenberg":
+		h.Gutenberg, err = strconv.ParseBool(value)
+	case "created":
+		h.Created, err = time.Parse(time.RFC3339, value)
+	case "error-bound":
+		h.ErrorBound, err = strconv.Atoi(value)
+	case "confidence":
+		h.Confidence, err = strconv.ParseFloat(value, 64)
+	default:
+		// Unknown keys are ignored so that newer writers stay readable
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", key, value)
+	}
+	return nil
+}
+
+// formatCount writes a number of files or tokens, which may be unknown
+func formatCount(n int) string {
+	if n == UnknownCount {
+		return unknownCountValue
+	}
+	return strconv.Itoa(n)
+}
+
+// parseCount reads a number of files or tokens written by formatCount
+func parseCount(value string) (int, error) {
+	if value == unknownCountValue {
+		return UnknownCount, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// addCounts adds numbers of files or tokens, which are unknown if either is
+func addCounts(a, b int) int {
+	if a == UnknownCount || b == UnknownCount {
+		return UnknownCount
+	}
+	return a + b
+}
+
+// addErrorBound adds the error bound of a table whose counts were multiplied
+// by factor to the header of a table combined from it. The combined bound
+// holds when every part's does, so its confidence drops by each part's
+// probability of failure.
+func (h *TableHeader) addErrorBound(part *TableHeader, factor float64) {
+	if part.ErrorBound == 0 {
+		return
+	}
+	if h.ErrorBound == 0 {
+		h.Confidence = 1
+	}
+	h.ErrorBound += int(math.Ceil(float64(part.ErrorBound) * factor))
+	h.Confidence = math.Round(max(h.Confidence-(1-part.Confidence), 0)*1e9) / 1e9
+}
+
+// subtractCounts subtracts numbers of files or tokens, clamping at zero; the
+// difference is unknown if either is
+func subtractCounts(a, b int) int {
+	if a == UnknownCount || b == UnknownCount {
+		return UnknownCount
+	}
+	return max(a-b, 0)
+}
+
+// BigramTable holds the bigram counts stored in a count file
+type BigramTable struct {
+	Header  *TableHeader   // Nil for legacy tables written without a header
+	Counts  map[string]int // Bigram counts
+	DocFreq map[string]int // Number of files each bigram occurs in, or nil if not counted
+	Total   int            // Sum of all counts
+}
+
+// Documents returns the number of files behind the table's document frequencies
+func (t *BigramTable) Documents() (int, error) {
+	if t.DocFreq == nil {
+		return 0, fmt.Errorf("table has no document frequencies (count with bigrams -df; a blend needs them in every file)")
+	}
+	if t.Header == nil || t.Header.Files <= 0 {
+		return 0, fmt.Errorf("table header does not record the number of files")
+	}
+	return t.Header.Files, nil
+}
+
+// LoadOptions controls how count tables are parsed and validated
+type LoadOptions struct {
+	Strict     bool       // Reject tables without a header and report malformed lines
+	Normalizer Normalizer // Normalization the table must have been counted with
+	Gutenberg  bool       // Whether texts are read as Project Gutenberg bodies only, which tables are expected to match
+	Warnings   io.Writer  // Receives warnings about checks that cannot be made or that failed without being errors, or nil
+}
+
+// warnGutenberg reports to opts.Warnings if a table was not counted the same
+// way as the texts are read, with or without -g. This is not an error, since
+// tables counted both ways are meant to be blended and compared.
+func (opts LoadOptions) warnGutenberg(path string, h *TableHeader) {
+	if opts.Warnings == nil || h.Gutenberg == opts.Gutenberg || h.Normalization == ImportedNormalization {
+		return
+	}
+	fmt.Fprintf(opts.Warnings, "Warning: %s: counted %s but texts are read %s\n",
+		path, gutenbergMode(h.Gutenberg), gutenbergMode(opts.Gutenberg))
+}
+
+// ReadBigramTable reads a count table written by WriteBigramTable or
+// WriteBinaryTable. A header, when present, is always checked against
+// opts.Normalizer. In strict mode a missing header or any malformed line is an
+// error that includes the line number; otherwise malformed lines are skipped.
+// The unknown normalization of an imported table and a table not counted with
+// opts.Gutenberg are reported to opts.Warnings.
+func ReadBigramTable(path string, opts LoadOptions) (*BigramTable, error) {
+	table, err := ParseBigramTable(path, opts.Strict)
+	if err != nil {
+		return nil, err
+	}
+
+	if table.Header == nil {
+		if opts.Strict {
+			return nil, fmt.Errorf("%s: missing %s header", path, tableMagic)
+		}
+		return table, nil
+	}
+	if err := table.Header.Check(opts.Normalizer); errors.Is(err, ErrImportedNormalization) {
+		if opts.Warnings != nil {
+			fmt.Fprintf(opts.Warnings, "Warning: %s: %v; it is assumed to match %s\n", path, err, opts.Normalizer.Name())
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	opts.warnGutenberg(path, table.Header)
+
+	return table, nil
+}
+
+// ParseBigramTable parses a count table without validating its header.
+// Binary tables are recognized by their magic bytes.
+func ParseBigramTable(path string, strict bool) (*BigramTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if magic, _ := reader.Peek(len(binaryMagic)); string(magic) == binaryMagic {
+		return readBinaryTable(reader, path)
+	}
+
+	table := &BigramTable{Counts: make(map[string]int)}
+	lineErr := func(n int, format string, args ...any) error {
+		return fmt.Errorf("%s:%d: %s", path, n, fmt.Sprintf(format, args...))
+	}
+
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	inHeader := false
+	columns := 0 // Number of fields per count line, fixed by the first one
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// A versioned table starts with a block of "#key<TAB>value" lines
+		if lineNum == 1 && strings.HasPrefix(line, "#"+tableMagic+"\t") {
+			table.Header = &TableHeader{}
+			inHeader = true
+		}
+		if inHeader {
+			if key, value, ok := parseHeaderLine(line); ok {
+				if err := table.Header.set(key, value); err != nil {
+					return nil, lineErr(lineNum, "%v", err)
+				}
+				continue
+			}
+			inHeader = false
+		}
+
+		parts := strings.Split(line, "\t")
+		if (len(parts) != 2 && len(parts) != 3) || (columns != 0 && len(parts) != columns) {
+			if strict {
+				return nil, lineErr(lineNum, "expected %d tab-separated fields, got %d", max(columns, 2), len(parts))
+			}
+			continue // Skip malformed lines
+		}
+
+		bigram := parts[0]
+		count, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || count < 0 {
+			if strict {
+				return nil, lineErr(lineNum, "invalid count %q", parts[1])
+			}
+			continue
+		}
+
+		if strict {
+			if utf8.RuneCountInString(bigram) != 2 {
+				return nil, lineErr(lineNum, "invalid bigram %q", bigram)
+			}
+			if _, dup := table.Counts[bigram]; dup {
+				return nil, lineErr(lineNum, "duplicate bigram %q", bigram)
+			}
+		}
+
+		// An optional third column holds the document frequency
+		if len(parts) == 3 {
+			df, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+			if err != nil || df < 0 {
+				if strict {
+					return nil, lineErr(lineNum, "invalid document frequency %q", parts[2])
+				}
+				continue
+			}
+			if table.DocFreq == nil {
+				table.DocFreq = make(map[string]int)
+			}
+			table.DocFreq[bigram] += df
+		}
+		columns = len(parts)
+
+		table.Counts[bigram] += count
+		table.Total += count
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return table, nil
+}
+
+// write writes the header as "#key<TAB>value" lines
+func (h *TableHeader) write(w io.Writer, magic string) {
+	for _, f := range h.fields(magic) {
+		fmt.Fprintf(w, "#%s\t%s\n", f[0], f[1])
+	}
+}
+
+// parseHeaderLine splits a "#key<TAB>value" header line. Keys are longer than
+// one character, which keeps them distinct from bigrams that start with '#'.
+func parseHeaderLine(line string) (string, string, bool) {
+	if !strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(line[1:], "\t")
+	if !ok || utf8.RuneCountInString(key) < 2 {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// WriteBigramTable writes the header followed by one "bigram<TAB>count" line
+// per bigram, ordered by descending count. Tables with document frequencies
+// get a third "df" column.
+func WriteBigramTable(w io.Writer, table *BigramTable) error {
+	bw := bufio.NewWriter(w)
+
+	if table.Header != nil {
+		table.Header.write(bw, tableMagic)
+	}
+
+	for _, bigram := range table.SortedBigrams() {
+		if table.DocFreq != nil {
+			fmt.Fprintf(bw, "%s\t%d\t%d\n", bigram, table.Counts[bigram], table.DocFreq[bigram])
+			continue
+		}
+		fmt.Fprintf(bw, "%s\t%d\n", bigram, table.Counts[bigram])
+	}
+
+	return bw.Flush()
+}
+
+// SortedBigrams returns the table's bigrams ordered by descending count, with
+// ties broken alphabetically so that output is deterministic.
+func (t *BigramTable) SortedBigrams() []string {
+	bigrams := make([]string, 0, len(t.Counts))
+	for bg := range t.Counts {
+		bigrams = append(bigrams, bg)
+	}
+	sort.Slice(bigrams, func(i, j int) bool {
+		ci, cj := t.Counts[bigrams[i]], t.Counts[bigrams[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return bigrams[i] < bigrams[j]
+	})
+	return bigrams
+}
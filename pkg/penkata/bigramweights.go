@@ -1,79 +1,87 @@
 package penkata
 
-import (
-	"bufio"
-	"math"
-	"os"
-	"strconv"
-	"strings"
-)
-
-// WeightTransform represents different methods to transform raw count weights
-type WeightTransform int
-
-const (
-	// Raw keeps the original count values
-	Raw WeightTransform = iota
-	// Log1p applies log1p to reduce impact of common bigrams
-	Log1p
-	// Normal converts counts to probabilities (count/total)
-	Normal
-)
+import "fmt"
 
 // BigramWeights stores the weights calculated from the TSV data
 type BigramWeights struct {
-	Weights   map[string]float64
-	Transform WeightTransform
-	Total     int
+	Weights    map[string]float64
+	Transform  WeightTransform
+	Total      int
+	Normalizer Normalizer // Normalization the counts were produced with
+	Smoothing  Smoother   // Smoothing applied before the transform, or nil
+	Overlay    *Overlay   // Emphasis rules applied after the transform, or nil
+
+	table *BigramTable  // Counts the weights were derived from
+	opts  WeightOptions // Options the weights were derived with
+}
+
+// WeightOptions controls how a count table is turned into weights
+type WeightOptions struct {
+	Normalizer Normalizer // Normalization of the scored words, matching the table's
+	Smoothing  Smoother   // Estimates counts for rare and unseen bigrams, or nil
+	Alphabet   []rune     // Target alphabet for smoothing; defaults to the table's characters
+	Overlay    *Overlay   // Emphasis rules applied after the transform, or nil
 }
 
-// LoadBigramWeights reads and processes the TSV file with the specified weight transformation
-func LoadBigramWeights(filepath string, transform WeightTransform) (*BigramWeights, error) {
-	file, err := os.Open(filepath)
+// LoadBigramWeights reads and processes the TSV file with the specified weight
+// transformation. Reference tables of the transform are read with the same
+// load options.
+func LoadBigramWeights(filepath string, transform WeightTransform, load LoadOptions, opts WeightOptions) (*BigramWeights, error) {
+	table, err := ReadBigramTable(filepath, load)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	weights := make(map[string]float64)
-	total := 0
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "\t")
-		if len(parts) != 2 {
-			continue // Skip malformed lines
-		}
-
-		bigram := parts[0]
-		count, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	for _, path := range TransformReferences(transform) {
+		ref, err := ReadBigramTable(path, load)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("reference table: %w", err)
 		}
+		SetTransformReference(transform, path, ref)
+	}
+	return NewBigramWeights(table, transform, opts)
+}
 
-		// Store raw counts for now
+// NewBigramWeights smooths the counts in a table if requested, applies the
+// weight transformation and then the overlay
+func NewBigramWeights(table *BigramTable, transform WeightTransform, opts WeightOptions) (*BigramWeights, error) {
+	weights := make(map[string]float64, len(table.Counts))
+	for bigram, count := range table.Counts {
 		weights[bigram] = float64(count)
-		total += count
 	}
 
-	switch transform {
-	case Log1p:
-		for bigram, count := range weights {
-			weights[bigram] = math.Log1p(count)
-		}
-	case Normal:
-		totalFloat := float64(total)
-		for bigram, count := range weights {
-			weights[bigram] = count / totalFloat
+	if opts.Smoothing != nil {
+		alphabet := opts.Alphabet
+		if len(alphabet) == 0 {
+			alphabet = TableAlphabet(table)
 		}
-	case Raw:
-		// Keep original counts
+		opts.Smoothing.Smooth(weights, alphabet)
+	}
+
+	if err := transform.Apply(weights, table); err != nil {
+		return nil, fmt.Errorf("applying %s: %w", transform, err)
+	}
+
+	if opts.Overlay != nil {
+		opts.Overlay.Apply(weights)
 	}
 
 	return &BigramWeights{
-		Weights:   weights,
-		Transform: transform,
-		Total:     total,
+		Weights:    weights,
+		Transform:  transform,
+		Total:      table.Total,
+		Normalizer: opts.Normalizer,
+		Smoothing:  opts.Smoothing,
+		Overlay:    opts.Overlay,
+		table:      table,
+		opts:       opts,
 	}, nil
 }
+
+// LeaveOut derives weights from the same table with one file's counts
+// removed, so that a file is not scored with weights inflated by its own bigrams
+func (w *BigramWeights) LeaveOut(f *FileCounts) (*BigramWeights, error) {
+	if err := checkTokens(w.table); err != nil {
+		return nil, err
+	}
+	return NewBigramWeights(w.table.Without(f), w.Transform, w.opts)
+}
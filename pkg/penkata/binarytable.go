@@ -0,0 +1,184 @@
+package penkata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// BinaryFormatVersion is the binary table format version written by WriteBinaryTable
+const BinaryFormatVersion = 1
+
+// binaryMagic starts every binary count table
+const binaryMagic = "PKBIGRAM"
+
+// Flags describing the optional sections of a binary table
+const (
+	binaryHasHeader  = 1 << 0
+	binaryHasDocFreq = 1 << 1
+)
+
+// WriteBinaryTable writes a table in the compact binary format: the magic
+// bytes, the format version and flags, the header fields, then the bigrams in
+// sorted order with their counts (and document frequencies) as varints, and
+// finally a CRC-32 checksum of everything before it.
+func WriteBinaryTable(w io.Writer, table *BigramTable) error {
+	var buf []byte
+	buf = append(buf, binaryMagic...)
+	buf = binary.AppendUvarint(buf, BinaryFormatVersion)
+
+	var flags byte
+	if table.Header != nil {
+		flags |= binaryHasHeader
+	}
+	if table.DocFreq != nil {
+		flags |= binaryHasDocFreq
+	}
+	buf = append(buf, flags)
+
+	if table.Header != nil {
+		fields := table.Header.fields(tableMagic)
+		buf = binary.AppendUvarint(buf, uint64(len(fields)))
+		for _, f := range fields {
+			buf = appendString(buf, f[0])
+			buf = appendString(buf, f[1])
+		}
+	}
+
+	bigrams := make([]string, 0, len(table.Counts))
+	for bigram := range table.Counts {
+		bigrams = append(bigrams, bigram)
+	}
+	sort.Strings(bigrams)
+
+	buf = binary.AppendUvarint(buf, uint64(len(bigrams)))
+	for _, bigram := range bigrams {
+		buf = appendString(buf, bigram)
+		buf = binary.AppendUvarint(buf, uint64(table.Counts[bigram]))
+		if table.DocFreq != nil {
+			buf = binary.AppendUvarint(buf, uint64(table.DocFreq[bigram]))
+		}
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	bw := bufio.NewWriter(w)
+	bw.Write(buf)
+	return bw.Flush()
+}
+
+// appendString appends a length-prefixed string
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// readBinaryTable reads a table written by WriteBinaryTable, verifying its checksum
+func readBinaryTable(r io.Reader, path string) (*BigramTable, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(data) < len(binaryMagic)+4 {
+		return nil, fmt.Errorf("%s: truncated binary table", path)
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("%s: checksum mismatch, the table is corrupt", path)
+	}
+
+	d := &binaryDecoder{data: body[len(binaryMagic):]}
+	if version := d.uvarint(); d.err == nil && version > BinaryFormatVersion {
+		return nil, fmt.Errorf("%s: unsupported binary format version %d (newest supported is %d)", path, version, BinaryFormatVersion)
+	}
+	flags := d.byte()
+
+	table := &BigramTable{Counts: make(map[string]int)}
+	if flags&binaryHasHeader != 0 {
+		table.Header = &TableHeader{}
+		for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+			key, value := d.string(), d.string()
+			if d.err != nil {
+				break
+			}
+			if err := table.Header.set(key, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	if flags&binaryHasDocFreq != 0 {
+		table.DocFreq = make(map[string]int)
+	}
+
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		bigram := d.string()
+		count := int(d.uvarint())
+		table.Counts[bigram] = count
+		table.Total += count
+		if table.DocFreq != nil {
+			table.DocFreq[bigram] = int(d.uvarint())
+		}
+	}
+
+	if d.err == nil && len(d.data) > 0 {
+		d.err = fmt.Errorf("%d unexpected trailing bytes", len(d.data))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%s: %w", path, d.err)
+	}
+
+	return table, nil
+}
+
+// binaryDecoder reads varints and strings from a buffer, remembering the
+// first error so that callers can check once at the end
+type binaryDecoder struct {
+	data []byte
+	err  error
+}
+
+// uvarint reads an unsigned varint
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = fmt.Errorf("malformed varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// byte reads a single byte
+func (d *binaryDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) == 0 {
+		d.err = fmt.Errorf("unexpected end of data")
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+// string reads a length-prefixed string
+func (d *binaryDecoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(len(d.data)) {
+		d.err = fmt.Errorf("unexpected end of data")
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
@@ -0,0 +1,108 @@
+package penkata
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	fun "github.com/colinhb/penkata/pkg/myfuncs"
+)
+
+// BlendPart is one table of a blend with its coefficient
+type BlendPart struct {
+	Name        string // Label used in the blended header, usually the file path
+	Table       *BigramTable
+	Coefficient float64 // Relative weight of this table in the blend
+}
+
+// BlendTables combines several tables into one whose relative frequencies are
+// the coefficient-weighted mean of each table's relative frequencies, so that
+// a large table does not drown out a small one. Coefficients are normalized
+// to sum to one. Counts are scaled so that every bigram counted in any part
+// keeps a count of at least its original count, which preserves precision
+// for transforms that depend on magnitudes; use the normal transform to score
+// with the blended probabilities themselves. Document frequencies are summed
+// if every part has them, as for parts counted from disjoint corpora.
+func BlendTables(parts []BlendPart) (*BigramTable, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no tables to blend")
+	}
+	if len(parts) == 1 {
+		return parts[0].Table, nil
+	}
+
+	sum := 0.0
+	for _, p := range parts {
+		if err := checkCompatible(parts[0].Table, p.Table); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Name, err)
+		}
+		if p.Coefficient <= 0 {
+			return nil, fmt.Errorf("blend coefficient for %s must be positive, got %g", p.Name, p.Coefficient)
+		}
+		if p.Table.Total == 0 {
+			return nil, fmt.Errorf("cannot blend empty table %s", p.Name)
+		}
+		sum += p.Coefficient
+	}
+
+	// Scale so that c_i/N_i·S >= 1 for every part
+	scale := 0.0
+	for _, p := range parts {
+		scale = math.Max(scale, float64(p.Table.Total)/(p.Coefficient/sum))
+	}
+
+	mixed := make(map[string]float64)
+	for _, p := range parts {
+		factor := p.Coefficient / sum / float64(p.Table.Total) * scale
+		for bigram, count := range p.Table.Counts {
+			mixed[bigram] += float64(count) * factor
+		}
+	}
+
+	result := &BigramTable{Counts: make(map[string]int, len(mixed))}
+	for bigram, count := range mixed {
+		c := int(math.Round(count))
+		result.Counts[bigram] = c
+		result.Total += c
+	}
+	result.DocFreq = make(map[string]int)
+	for _, p := range parts {
+		if p.Table.DocFreq == nil {
+			result.DocFreq = nil
+			break
+		}
+		fun.MergeMaps(result.DocFreq, p.Table.DocFreq)
+	}
+	result.Header = blendHeader(parts, sum, scale)
+
+	return result, nil
+}
+
+// blendHeader describes a blend whose counts were scaled by scale, or returns
+// nil if any part lacks a header
+func blendHeader(parts []BlendPart, sum, scale float64) *TableHeader {
+	var names []string
+	header := &TableHeader{Version: TableFormatVersion, Gutenberg: true}
+	for _, p := range parts {
+		h := p.Table.Header
+		if h == nil {
+			return nil
+		}
+		names = append(names, strconv.FormatFloat(p.Coefficient/sum, 'g', 4, 64)+":"+p.Name)
+		header.Files = addCounts(header.Files, h.Files)
+		header.Tokens = addCounts(header.Tokens, h.Tokens)
+		if header.Normalization != ImportedNormalization {
+			header.Normalization = h.Normalization
+		}
+		header.CaseMode = h.CaseMode
+		header.Counting = h.CountingMode()
+		header.Gutenberg = header.Gutenberg && h.Gutenberg
+		header.addErrorBound(h, p.Coefficient/sum/float64(p.Table.Total)*scale)
+		if h.Created.After(header.Created) {
+			header.Created = h.Created
+		}
+	}
+	header.Corpus = "blend(" + strings.Join(names, ", ") + ")"
+	return header
+}
@@ -0,0 +1,170 @@
+package penkata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// comparePseudoCount is added to every bigram of the union vocabulary when
+// estimating probabilities for the KL divergence and log ratios, so that a
+// bigram missing from one table does not make them infinite
+const comparePseudoCount = 0.5
+
+// BigramRatio is a bigram's relative frequency in two tables
+type BigramRatio struct {
+	Bigram   string
+	P, Q     float64 // Smoothed relative frequencies in the first and second table
+	LogRatio float64 // log2(P/Q)
+}
+
+// TableComparison holds distances between the bigram distributions of two tables
+type TableComparison struct {
+	KL         float64       // KL(P‖Q) in bits, with add-½ smoothing
+	KLReverse  float64       // KL(Q‖P) in bits, with add-½ smoothing
+	JS         float64       // Jensen–Shannon divergence in bits, between 0 and 1
+	Cosine     float64       // Cosine similarity of the count vectors
+	Spearman   float64       // Rank correlation of the counts over the union vocabulary
+	Over       []BigramRatio // Most over-represented bigrams in the first table
+	Under      []BigramRatio // Most under-represented bigrams in the first table
+	TopK       int           // Number of most frequent bigrams compared for overlap
+	TopOverlap int           // Number of bigrams in the top K of both tables
+}
+
+// CompareTables compares the bigram distributions of two tables, reporting
+// the n most over- and under-represented bigrams and the overlap of the topK
+// most frequent bigrams. Negative topK and n are treated as zero.
+func CompareTables(p, q *BigramTable, topK, n int) (*TableComparison, error) {
+	if err := checkCompatible(p, q); err != nil {
+		return nil, err
+	}
+	topK, n = max(topK, 0), max(n, 0)
+	if p.Total == 0 || q.Total == 0 {
+		return nil, fmt.Errorf("cannot compare empty tables")
+	}
+
+	vocab := unionBigrams(p, q)
+	c := &TableComparison{TopK: topK}
+
+	// Relative frequencies, raw and smoothed over the union vocabulary
+	smoothed := func(t *BigramTable, bigram string) float64 {
+		return (float64(t.Counts[bigram]) + comparePseudoCount) /
+			(float64(t.Total) + comparePseudoCount*float64(len(vocab)))
+	}
+	var dot, normP, normQ float64
+	ratios := make([]BigramRatio, 0, len(vocab))
+	for _, bigram := range vocab {
+		cp, cq := float64(p.Counts[bigram]), float64(q.Counts[bigram])
+		dot += cp * cq
+		normP += cp * cp
+		normQ += cq * cq
+
+		rp, rq := cp/float64(p.Total), cq/float64(q.Total)
+		m := (rp + rq) / 2
+		if rp > 0 {
+			c.JS += rp * math.Log2(rp/m) / 2
+		}
+		if rq > 0 {
+			c.JS += rq * math.Log2(rq/m) / 2
+		}
+
+		sp, sq := smoothed(p, bigram), smoothed(q, bigram)
+		c.KL += sp * math.Log2(sp/sq)
+		c.KLReverse += sq * math.Log2(sq/sp)
+		ratios = append(ratios, BigramRatio{Bigram: bigram, P: sp, Q: sq, LogRatio: math.Log2(sp / sq)})
+	}
+	c.Cosine = dot / math.Sqrt(normP*normQ)
+	c.Spearman = spearman(vocab, p, q)
+
+	n = min(n, len(ratios))
+	c.Over = extremeRatios(ratios, n, 1)
+	c.Under = extremeRatios(ratios, n, -1)
+
+	inP := make(map[string]bool, topK)
+	for _, bigram := range p.SortedBigrams()[:min(topK, len(p.Counts))] {
+		inP[bigram] = true
+	}
+	for _, bigram := range q.SortedBigrams()[:min(topK, len(q.Counts))] {
+		if inP[bigram] {
+			c.TopOverlap++
+		}
+	}
+
+	return c, nil
+}
+
+// extremeRatios returns the n bigrams with the largest log ratios, or the
+// smallest if sign is negative, with ties broken alphabetically
+func extremeRatios(ratios []BigramRatio, n int, sign float64) []BigramRatio {
+	sorted := append([]BigramRatio(nil), ratios...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].LogRatio != sorted[j].LogRatio {
+			return sign*sorted[i].LogRatio > sign*sorted[j].LogRatio
+		}
+		return sorted[i].Bigram < sorted[j].Bigram
+	})
+	return sorted[:n]
+}
+
+// unionBigrams returns the bigrams counted in either table, sorted
+func unionBigrams(p, q *BigramTable) []string {
+	seen := make(map[string]bool, len(p.Counts))
+	var vocab []string
+	for _, t := range []*BigramTable{p, q} {
+		for bigram := range t.Counts {
+			if !seen[bigram] {
+				seen[bigram] = true
+				vocab = append(vocab, bigram)
+			}
+		}
+	}
+	sort.Strings(vocab)
+	return vocab
+}
+
+// spearman returns the Pearson correlation of the count ranks of the
+// vocabulary in both tables, with tied counts sharing their mean rank
+func spearman(vocab []string, p, q *BigramTable) float64 {
+	rp, rq := countRanks(vocab, p), countRanks(vocab, q)
+	n := float64(len(vocab))
+	meanRank := (n + 1) / 2
+
+	var cov, varP, varQ float64
+	for i := range vocab {
+		dp, dq := rp[i]-meanRank, rq[i]-meanRank
+		cov += dp * dq
+		varP += dp * dp
+		varQ += dq * dq
+	}
+	if varP == 0 || varQ == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varP*varQ)
+}
+
+// countRanks returns the rank of each bigram's count in a table, starting at 1
+// for the smallest count
+func countRanks(vocab []string, t *BigramTable) []float64 {
+	order := make([]int, len(vocab))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return t.Counts[vocab[order[i]]] < t.Counts[vocab[order[j]]]
+	})
+
+	ranks := make([]float64, len(vocab))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && t.Counts[vocab[order[end]]] == t.Counts[vocab[order[start]]] {
+			end++
+		}
+		// Ranks start..end-1 (zero-based) share their mean, one-based
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			ranks[i] = rank
+		}
+		start = end
+	}
+	return ranks
+}
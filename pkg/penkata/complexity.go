@@ -0,0 +1,206 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LetterComplexity describes how hard a letterform is to write
+type LetterComplexity struct {
+	Strokes    int // Number of separate strokes
+	Directions int // Changes of direction within strokes
+	Lifts      int // Pen lifts between strokes
+}
+
+// Cost returns the combined difficulty of the letterform
+func (c LetterComplexity) Cost() float64 {
+	return float64(c.Strokes + c.Directions + c.Lifts)
+}
+
+// JoinComplexity is the extra difficulty of joins matching a pattern
+type JoinComplexity struct {
+	Pattern string // Bigram, with '?' matching any character
+	Cost    float64
+}
+
+// ComplexityTable holds letterform and join difficulties for a hand
+type ComplexityTable struct {
+	Name    string                    // Hand or source file
+	Letters map[rune]LetterComplexity // Lowercase letterforms; capitals fall back to these
+	Joins   []JoinComplexity          // Join costs, all matching patterns are added
+}
+
+// DefaultComplexity returns built-in letterform difficulties for a standard
+// Latin italic hand: one continuous stroke for most minuscules, extra strokes
+// and lifts for crossbars, dots and diagonals, and extra cost for the high
+// joins out of o, r, v and w and the joins into round letters, which need a
+// retrace over the top of the bowl.
+func DefaultComplexity() *ComplexityTable {
+	letters := map[rune]LetterComplexity{
+		'a': {1, 2, 0}, 'b': {1, 2, 0}, 'c': {1, 1, 0}, 'd': {1, 3, 0},
+		'e': {1, 2, 0}, 'f': {2, 2, 1}, 'g': {1, 3, 0}, 'h': {1, 2, 0},
+		'i': {2, 1, 1}, 'j': {2, 2, 1}, 'k': {2, 3, 1}, 'l': {1, 1, 0},
+		'm': {1, 4, 0}, 'n': {1, 2, 0}, 'o': {1, 2, 0}, 'p': {2, 3, 1},
+		'q': {1, 3, 0}, 'r': {1, 2, 0}, 's': {1, 3, 0}, 't': {2, 1, 1},
+		'u': {1, 3, 0}, 'v': {1, 1, 0}, 'w': {1, 3, 0}, 'x': {2, 1, 1},
+		'y': {1, 3, 0}, 'z': {1, 3, 0},
+	}
+	var joins []JoinComplexity
+	for _, exit := range "orvw" {
+		joins = append(joins, JoinComplexity{Pattern: string(exit) + "?", Cost: 0.5})
+	}
+	for _, entry := range "acdgoq" {
+		joins = append(joins, JoinComplexity{Pattern: "?" + string(entry), Cost: 0.5})
+	}
+	return &ComplexityTable{Name: "italic", Letters: letters, Joins: joins}
+}
+
+// LoadComplexityTable reads a complexity table. Each line is either a letter
+// followed by its stroke, direction change and pen lift counts, or a two
+// character join pattern ('?' matches any character) followed by its cost,
+// separated by tabs. Blank lines and lines starting with '#' are ignored.
+func LoadComplexityTable(path string) (*ComplexityTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := &ComplexityTable{Name: path, Letters: make(map[rune]LetterComplexity)}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		switch utf8.RuneCountInString(parts[0]) {
+		case 1:
+			if len(parts) != 4 {
+				return nil, fmt.Errorf("%s:%d: expected letter, strokes, direction changes and lifts", path, lineNum)
+			}
+			var values [3]int
+			for i := range values {
+				values[i], err = strconv.Atoi(parts[i+1])
+				if err != nil || values[i] < 0 {
+					return nil, fmt.Errorf("%s:%d: invalid count %q", path, lineNum, parts[i+1])
+				}
+			}
+			r, _ := utf8.DecodeRuneInString(parts[0])
+			table.Letters[unicode.ToLower(r)] = LetterComplexity{values[0], values[1], values[2]}
+		case 2:
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("%s:%d: expected join pattern and cost", path, lineNum)
+			}
+			cost, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid cost %q", path, lineNum, parts[1])
+			}
+			table.Joins = append(table.Joins, JoinComplexity{Pattern: parts[0], Cost: cost})
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a letter or a two character join pattern", path, lineNum)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return table, nil
+}
+
+// Letter returns the cost of writing a character; characters without a
+// letterform in the table, including the word boundary, cost nothing
+func (t *ComplexityTable) Letter(r rune) float64 {
+	if c, ok := t.Letters[r]; ok {
+		return c.Cost()
+	}
+	return t.Letters[unicode.ToLower(r)].Cost()
+}
+
+// Bigram returns the cost of writing both characters of a bigram and the join between them
+func (t *ComplexityTable) Bigram(bigram string) float64 {
+	cost := 0.0
+	for _, r := range bigram {
+		cost += t.Letter(r)
+	}
+	lower := strings.ToLower(bigram)
+	for _, j := range t.Joins {
+		if matchPattern(j.Pattern, lower) {
+			cost += j.Cost
+		}
+	}
+	return cost
+}
+
+// strokeTransform combines weights with letterform complexity:
+// stroke:mul[:TABLE] multiplies each weight by (1+c)/(1+c̄), and
+// stroke:add:λ[:TABLE] adds λ·w̄·c/c̄, where c is the bigram's complexity,
+// c̄ the mean complexity and w̄ the mean weight. Both keep the overall scale
+// of the weights. TABLE defaults to the built-in italic hand; its path cannot
+// contain ':' or '+'.
+func strokeTransform(args []string) (WeightTransform, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected mode mul or add:λ")
+	}
+
+	mode, rest := args[0], args[1:]
+	spec := "stroke:" + mode
+	lambda := 0.0
+	switch mode {
+	case "mul":
+	case "add":
+		v, err := parseFloatArgs(rest[:min(1, len(rest))], 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		lambda, rest = v[0], rest[1:]
+		spec = transformSpec(spec, lambda)
+	default:
+		return nil, fmt.Errorf("unknown mode %q (expected mul or add)", mode)
+	}
+
+	table := DefaultComplexity()
+	switch len(rest) {
+	case 0:
+	case 1:
+		var err error
+		if table, err = LoadComplexityTable(rest[0]); err != nil {
+			return nil, err
+		}
+		spec += ":" + rest[0]
+	default:
+		return nil, fmt.Errorf("too many arguments (the table path cannot contain ':' or '+')")
+	}
+
+	return NewTransform(spec, func(weights map[string]float64) {
+		if len(weights) == 0 {
+			return
+		}
+		costs := make(map[string]float64, len(weights))
+		meanCost, meanWeight := 0.0, 0.0
+		for bigram, w := range weights {
+			costs[bigram] = table.Bigram(bigram)
+			meanCost += costs[bigram]
+			meanWeight += w
+		}
+		meanCost /= float64(len(weights))
+		meanWeight /= float64(len(weights))
+
+		for bigram, w := range weights {
+			if mode == "mul" {
+				weights[bigram] = w * (1 + costs[bigram]) / (1 + meanCost)
+			} else if meanCost > 0 {
+				weights[bigram] = w + lambda*meanWeight*costs[bigram]/meanCost
+			}
+		}
+	}), nil
+}
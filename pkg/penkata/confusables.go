@@ -0,0 +1,216 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of confusable entries
+const (
+	ConfusableSequence = "seq"  // Letter sequences such as rn and m
+	ConfusablePair     = "pair" // Minimal-pair words such as modern and modem
+)
+
+// Confusable is a pair of letter sequences or words that are easily misread
+// for each other in handwriting
+type Confusable struct {
+	Kind   string // ConfusableSequence or ConfusablePair
+	A, B   string // Lowercase sides of the confusion
+	Weight float64
+}
+
+// ConfusableTable holds the confusions practiced by legibility drills
+type ConfusableTable struct {
+	Name    string // Built-in table or source file
+	Entries []Confusable
+}
+
+// DefaultConfusables returns common handwriting confusions and minimal-pair
+// words that differ only in one of them
+func DefaultConfusables() *ConfusableTable {
+	seq := func(a, b string, weight float64) Confusable {
+		return Confusable{Kind: ConfusableSequence, A: a, B: b, Weight: weight}
+	}
+	pair := func(a, b string) Confusable {
+		return Confusable{Kind: ConfusablePair, A: a, B: b, Weight: 2}
+	}
+	return &ConfusableTable{
+		Name: "default",
+		Entries: []Confusable{
+			seq("rn", "m", 1),
+			seq("cl", "d", 1),
+			seq("vv", "w", 1),
+			seq("a", "o", 0.5),
+			seq("n", "u", 0.5),
+			pair("modern", "modem"),
+			pair("burn", "bum"),
+			pair("warn", "warm"),
+			pair("clear", "dear"),
+			pair("close", "dose"),
+			pair("clown", "down"),
+			pair("last", "lost"),
+			pair("hat", "hot"),
+			pair("bad", "bod"),
+		},
+	}
+}
+
+// LoadConfusables reads a confusables table with one "kind<TAB>A/B<TAB>weight"
+// entry per line, where kind is "seq" for letter sequences or "pair" for
+// minimal-pair words. Blank lines and lines starting with '#' are ignored.
+func LoadConfusables(path string) (*ConfusableTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := &ConfusableTable{Name: path}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected kind, A/B and weight", path, lineNum)
+		}
+		if parts[0] != ConfusableSequence && parts[0] != ConfusablePair {
+			return nil, fmt.Errorf("%s:%d: unknown kind %q (expected %s or %s)", path, lineNum, parts[0], ConfusableSequence, ConfusablePair)
+		}
+		a, b, ok := strings.Cut(strings.ToLower(parts[1]), "/")
+		if !ok || a == "" || b == "" {
+			return nil, fmt.Errorf("%s:%d: expected two sides separated by '/' in %q", path, lineNum, parts[1])
+		}
+		weight, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid weight %q", path, lineNum, parts[2])
+		}
+
+		table.Entries = append(table.Entries, Confusable{Kind: parts[0], A: a, B: b, Weight: weight})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(table.Entries) == 0 {
+		return nil, fmt.Errorf("%s: no confusables", path)
+	}
+
+	return table, nil
+}
+
+// ConfusableScorer scores windows for legibility drills. As the base scorer of
+// window parameters, it replaces the bigram score with the weighted coverage
+// of the table's confusions.
+type ConfusableScorer struct {
+	Table *ConfusableTable
+}
+
+// Features returns the sides of the table's confusions present in a word, as
+// "kind:side" so that a sequence and a minimal-pair word with the same
+// letters are told apart. Sequences of one or two letters are looked up in
+// the word's letters and bigrams; longer sequences and minimal-pair words in
+// the normalized word.
+func (s *ConfusableScorer) Features(word string, bigrams []string) []string {
+	normalized := strings.ToLower(normalizeWord(word))
+	lowered := make([]string, len(bigrams))
+	for i, bigram := range bigrams {
+		lowered[i] = strings.ToLower(bigram)
+	}
+
+	var features []string
+	seen := make(map[string]bool)
+	for _, e := range s.Table.Entries {
+		for _, side := range []string{e.A, e.B} {
+			feature := confusableFeature(e.Kind, side)
+			if seen[feature] {
+				continue
+			}
+			seen[feature] = true
+			var found bool
+			switch {
+			case e.Kind == ConfusablePair:
+				found = normalized == side
+			case utf8.RuneCountInString(side) == 1:
+				found = containsLetter(lowered, side)
+			case utf8.RuneCountInString(side) == 2:
+				found = slices.Contains(lowered, side)
+			default:
+				found = strings.Contains(normalized, side)
+			}
+			if found {
+				features = append(features, feature)
+			}
+		}
+	}
+	return features
+}
+
+// BaseScore returns the weighted coverage of the window: each side of a
+// confusion present in the window earns half of the entry's weight, so
+// windows that contrast both sides score highest
+func (s *ConfusableScorer) BaseScore(w *Window) float64 {
+	coverage := 0.0
+	for _, e := range s.Table.Entries {
+		if s.found(w, e.Kind, e.A) {
+			coverage += e.Weight / 2
+		}
+		if s.found(w, e.Kind, e.B) {
+			coverage += e.Weight / 2
+		}
+	}
+	return coverage
+}
+
+// Columns returns the name of the reported confusables column
+func (s *ConfusableScorer) Columns() []string {
+	return []string{"confusables"}
+}
+
+// Report returns the multi-letter sequences and minimal-pair words present in
+// the window; single letters are left out as nearly every window has them
+func (s *ConfusableScorer) Report(w *Window) []string {
+	var present []string
+	for _, e := range s.Table.Entries {
+		for _, side := range []string{e.A, e.B} {
+			if utf8.RuneCountInString(side) > 1 && s.found(w, e.Kind, side) && !slices.Contains(present, side) {
+				present = append(present, side)
+			}
+		}
+	}
+	sort.Strings(present)
+	return []string{strings.Join(present, ",")}
+}
+
+// found reports whether a side of a confusion of the given kind is present in
+// the window
+func (s *ConfusableScorer) found(w *Window, kind, side string) bool {
+	return w.FeatureCount(s, confusableFeature(kind, side)) > 0
+}
+
+// confusableFeature names the presence of a side of a confusion
+func confusableFeature(kind, side string) string {
+	return kind + ":" + side
+}
+
+// containsLetter reports whether any of the bigrams starts with letter. Every
+// character starts exactly one bigram, so this finds each letter written.
+func containsLetter(bigrams []string, letter string) bool {
+	for _, bigram := range bigrams {
+		if strings.HasPrefix(bigram, letter) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package penkata
+
+import (
+	"math"
+	"sort"
+)
+
+// CorpusStats summarizes a corpus or a bigram table, to judge whether it is
+// worth scoring
+type CorpusStats struct {
+	Files         int          // Number of files counted, 0 if unknown
+	Tokens        int          // Number of words counted, 0 if unknown
+	Types         int          // Number of distinct normalized words, 0 if unknown
+	Bigrams       int          // Number of distinct bigrams
+	Total         int          // Sum of all bigram counts
+	Chars         map[rune]int // Character counts, excluding word boundaries
+	CharEntropy   float64      // Entropy of the character distribution in bits
+	BigramEntropy float64      // Entropy of the bigram distribution in bits
+	ZipfExponent  float64      // s in count ∝ rank^-s, fitted by least squares on log scales
+	ZipfR2        float64      // Coefficient of determination of the Zipf fit
+	FileTokens    []int        // Words per file in ascending order, nil if unknown
+
+	table  *BigramTable
+	counts []int // Bigram counts in descending order
+}
+
+// NewCorpusStats computes statistics of a table. The word frequencies and the
+// number of words in each file of the corpus it was counted from are
+// optional; without them, types and file sizes are unknown.
+func NewCorpusStats(table *BigramTable, words map[string]int, fileTokens []int) *CorpusStats {
+	s := &CorpusStats{
+		Bigrams: len(table.Counts),
+		Total:   table.Total,
+		Chars:   make(map[rune]int),
+		table:   table,
+	}
+	if table.Header != nil {
+		s.Files = max(table.Header.Files, 0)
+		s.Tokens = max(table.Header.Tokens, 0)
+	}
+
+	if fileTokens != nil {
+		s.Types = len(words)
+		s.FileTokens = append([]int(nil), fileTokens...)
+		sort.Ints(s.FileTokens)
+	}
+
+	// Every character of a word starts exactly one bigram
+	charTotal := 0
+	for bigram, count := range table.Counts {
+		if r := []rune(bigram)[0]; r != '_' {
+			s.Chars[r] += count
+			charTotal += count
+		}
+	}
+	for _, count := range s.Chars {
+		s.CharEntropy += entropyTerm(count, charTotal)
+	}
+
+	s.counts = make([]int, 0, len(table.Counts))
+	for _, count := range table.Counts {
+		s.counts = append(s.counts, count)
+		s.BigramEntropy += entropyTerm(count, table.Total)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(s.counts)))
+	s.ZipfExponent, s.ZipfR2 = zipfFit(s.counts)
+
+	return s
+}
+
+// BigramsForMass returns the number of most frequent bigrams that together
+// hold at least the given fraction of the total count
+func (s *CorpusStats) BigramsForMass(mass float64) int {
+	cumulative := 0
+	for i, count := range s.counts {
+		if float64(cumulative) >= mass*float64(s.Total) {
+			return i
+		}
+		cumulative += count
+	}
+	return len(s.counts)
+}
+
+// FileTokenQuantile returns the q-th quantile of the words per file, using
+// the nearest rank, or 0 if file sizes are unknown
+func (s *CorpusStats) FileTokenQuantile(q float64) int {
+	if len(s.FileTokens) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(s.FileTokens)))) - 1
+	return s.FileTokens[min(max(i, 0), len(s.FileTokens)-1)]
+}
+
+// AlphabetCoverage reports how well a table covers a target alphabet
+type AlphabetCoverage struct {
+	Letters  int     // Alphabet characters that occur in the table
+	Missing  []rune  // Alphabet characters that do not occur
+	Bigrams  int     // Distinct bigrams made only of alphabet characters and word boundaries
+	Possible int     // Number of such bigrams, excluding a boundary pair
+	Mass     float64 // Fraction of the total count held by those bigrams
+}
+
+// Coverage reports how well the table covers an alphabet
+func (s *CorpusStats) Coverage(alphabet []rune) AlphabetCoverage {
+	c := AlphabetCoverage{Possible: (len(alphabet)+1)*(len(alphabet)+1) - 1}
+	for _, r := range alphabet {
+		if s.Chars[r] > 0 {
+			c.Letters++
+		} else {
+			c.Missing = append(c.Missing, r)
+		}
+	}
+
+	within := 0
+	for _, count := range s.table.Filter(alphabet, 1).Counts {
+		c.Bigrams++
+		within += count
+	}
+	if s.Total > 0 {
+		c.Mass = float64(within) / float64(s.Total)
+	}
+	return c
+}
+
+// entropyTerm returns -p·log2(p) for p = count/total
+func entropyTerm(count, total int) float64 {
+	if count <= 0 || total <= 0 {
+		return 0
+	}
+	p := float64(count) / float64(total)
+	return -p * math.Log2(p)
+}
+
+// zipfFit fits log(count) = a - s·log(rank) by least squares to counts in
+// descending order, returning s and the coefficient of determination
+func zipfFit(counts []int) (float64, float64) {
+	n := float64(len(counts))
+	if n < 2 {
+		return 0, 0
+	}
+	var sumX, sumY, sumXX, sumXY, sumYY float64
+	for i, count := range counts {
+		x, y := math.Log(float64(i+1)), math.Log(float64(count))
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+		sumYY += y * y
+	}
+	covXY := sumXY - sumX*sumY/n
+	varX := sumXX - sumX*sumX/n
+	varY := sumYY - sumY*sumY/n
+	if varX == 0 || varY == 0 {
+		return 0, 0
+	}
+	return -covXY / varX, covXY * covXY / (varX * varY)
+}
@@ -0,0 +1,182 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Names of the families balanced against each other by FamilyScorer
+const (
+	AscenderFamily  = "ascenders"
+	DescenderFamily = "descenders"
+)
+
+// LetterFamily is a group of lowercase letters that share a basic shape
+type LetterFamily struct {
+	Name    string
+	Letters string
+}
+
+// DefaultFamilies returns the letter families commonly used in penmanship
+// instruction
+func DefaultFamilies() []LetterFamily {
+	return []LetterFamily{
+		{Name: "c-shapes", Letters: "acdgoq"},
+		{Name: "arches", Letters: "hmnr"},
+		{Name: DescenderFamily, Letters: "gjpqy"},
+		{Name: AscenderFamily, Letters: "bdfhklt"},
+	}
+}
+
+// LoadFamilies reads letter families with one "name<TAB>letters" definition
+// per line. Blank lines and lines starting with '#' are ignored. Families
+// named "ascenders" and "descenders" are balanced against each other.
+func LoadFamilies(path string) ([]LetterFamily, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var families []LetterFamily
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, letters, ok := strings.Cut(line, "\t")
+		letters = strings.TrimSpace(letters)
+		if !ok || name == "" || letters == "" {
+			return nil, fmt.Errorf("%s:%d: expected family name and letters separated by a tab", path, lineNum)
+		}
+		families = append(families, LetterFamily{Name: name, Letters: strings.ToLower(letters)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(families) == 0 {
+		return nil, fmt.Errorf("%s: no letter families", path)
+	}
+
+	return families, nil
+}
+
+// FamilyScorer rewards windows that cover the letters of every family and
+// balance ascenders against descenders
+type FamilyScorer struct {
+	Families []LetterFamily
+	Weight   float64 // Strength λ in [0, 1]
+}
+
+// Features returns the lowercase letters of a word. Every character starts
+// exactly one of the word's bigrams, so counting first characters counts each
+// letter once.
+func (s *FamilyScorer) Features(word string, bigrams []string) []string {
+	var letters []string
+	for _, bigram := range bigrams {
+		if r, _ := utf8.DecodeRuneInString(bigram); unicode.IsLetter(r) {
+			letters = append(letters, string(unicode.ToLower(r)))
+		}
+	}
+	return letters
+}
+
+// Score scales the score by (1-λ) + λ·coverage·balance, where coverage is the
+// mean fraction of each family's letters present in the window and balance
+// is 1 - |a-d|/(a+d) for a ascender and d descender letters written.
+//
+// Coverage is a fraction rather than whether a family is complete because a
+// short window rarely holds every letter of every family: requiring complete
+// families would score most windows alike, while the fraction still ranks a
+// window missing one letter of a family above one missing several, and is 1
+// exactly when every family is covered.
+func (s *FamilyScorer) Score(w *Window, score float64) float64 {
+	coverage := 0.0
+	for _, f := range s.Families {
+		present, total := s.familyCoverage(w, f)
+		coverage += float64(present) / float64(total)
+	}
+	coverage /= float64(len(s.Families))
+
+	return score * (1 - s.Weight + s.Weight*coverage*s.balance(w))
+}
+
+// Columns returns one coverage column per family, followed by the balance column
+func (s *FamilyScorer) Columns() []string {
+	columns := make([]string, 0, len(s.Families)+1)
+	for _, f := range s.Families {
+		columns = append(columns, f.Name)
+	}
+	return append(columns, "asc:desc")
+}
+
+// Report returns the present and total letters of each family, followed by
+// the number of ascender and descender letters written
+func (s *FamilyScorer) Report(w *Window) []string {
+	report := make([]string, 0, len(s.Families)+1)
+	for _, f := range s.Families {
+		present, total := s.familyCoverage(w, f)
+		report = append(report, fmt.Sprintf("%d/%d", present, total))
+	}
+	asc, desc := s.familyCount(w, AscenderFamily), s.familyCount(w, DescenderFamily)
+	return append(report, fmt.Sprintf("%d:%d", asc, desc))
+}
+
+// balance returns how evenly ascenders and descenders are written, or 1 if
+// the families do not define both
+func (s *FamilyScorer) balance(w *Window) float64 {
+	if !s.hasFamily(AscenderFamily) || !s.hasFamily(DescenderFamily) {
+		return 1
+	}
+	asc, desc := s.familyCount(w, AscenderFamily), s.familyCount(w, DescenderFamily)
+	if asc+desc == 0 {
+		return 0
+	}
+	return 1 - math.Abs(float64(asc-desc))/float64(asc+desc)
+}
+
+// hasFamily reports whether a family with the given name is defined
+func (s *FamilyScorer) hasFamily(name string) bool {
+	for _, f := range s.Families {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// familyCount returns the number of letters written from the named families
+func (s *FamilyScorer) familyCount(w *Window, name string) int {
+	count := 0
+	for _, f := range s.Families {
+		if f.Name != name {
+			continue
+		}
+		for _, r := range f.Letters {
+			count += w.FeatureCount(s, string(r))
+		}
+	}
+	return count
+}
+
+// familyCoverage returns the number of the family's letters present in the
+// window and the number of letters in the family
+func (s *FamilyScorer) familyCoverage(w *Window, f LetterFamily) (int, int) {
+	present, total := 0, 0
+	for _, r := range f.Letters {
+		total++
+		if w.FeatureCount(s, string(r)) > 0 {
+			present++
+		}
+	}
+	return present, max(total, 1)
+}
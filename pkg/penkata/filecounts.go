@@ -0,0 +1,189 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileCountsMagic is the header key on the first line of every per-file counts file
+const fileCountsMagic = "penkata-file-counts"
+
+// fileTokensKey takes the place of a bigram on the line holding a file's
+// word count; it cannot be mistaken for a bigram, which has two characters
+const fileTokensKey = "#tokens"
+
+// RelativePath returns path relative to the corpus root with forward slashes,
+// so that per-file counts can be matched across machines and working directories
+func RelativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// WriteFileCounts writes the header followed by one "path<TAB>bigram<TAB>count"
+// line per bigram of each file, ordered by path and then by descending count.
+// Each file's lines are preceded by a "path<TAB>#tokens<TAB>count" line
+// holding its word count.
+func WriteFileCounts(w io.Writer, header *TableHeader, files []*FileCounts) error {
+	bw := bufio.NewWriter(w)
+	header.write(bw, fileCountsMagic)
+
+	sorted := make([]*FileCounts, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	for _, f := range sorted {
+		fmt.Fprintf(bw, "%s\t%s\t%d\n", f.Path, fileTokensKey, f.Tokens)
+		table := &BigramTable{Counts: f.Counts}
+		for _, bigram := range table.SortedBigrams() {
+			fmt.Fprintf(bw, "%s\t%s\t%d\n", f.Path, bigram, f.Counts[bigram])
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadFileCounts reads a file written by WriteFileCounts and returns the
+// counts keyed by path. The header is checked against opts.Normalizer, and
+// counts not counted with opts.Gutenberg are reported to opts.Warnings; in
+// strict mode malformed lines are reported with their line numbers.
+func ReadFileCounts(path string, opts LoadOptions) (*TableHeader, map[string]*FileCounts, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var header *TableHeader
+	files := make(map[string]*FileCounts)
+	lineErr := func(n int, format string, args ...any) error {
+		return fmt.Errorf("%s:%d: %s", path, n, fmt.Sprintf(format, args...))
+	}
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	inHeader := false
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		if lineNum == 1 {
+			if !strings.HasPrefix(line, "#"+fileCountsMagic+"\t") {
+				return nil, nil, lineErr(lineNum, "missing %s header", fileCountsMagic)
+			}
+			header = &TableHeader{}
+			inHeader = true
+		}
+		if inHeader {
+			if key, value, ok := parseHeaderLine(line); ok {
+				if err := header.set(key, value); err != nil {
+					return nil, nil, lineErr(lineNum, "%v", err)
+				}
+				continue
+			}
+			inHeader = false
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			if opts.Strict {
+				return nil, nil, lineErr(lineNum, "expected 3 tab-separated fields, got %d", len(parts))
+			}
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || count < 0 {
+			if opts.Strict {
+				return nil, nil, lineErr(lineNum, "invalid count %q", parts[2])
+			}
+			continue
+		}
+
+		fc, ok := files[parts[0]]
+		if !ok {
+			fc = &FileCounts{Path: parts[0], Counts: make(map[string]int)}
+			files[parts[0]] = fc
+		}
+		if parts[1] == fileTokensKey {
+			fc.Tokens += count
+			continue
+		}
+		fc.Counts[parts[1]] += count
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if header == nil {
+		return nil, nil, fmt.Errorf("%s: missing %s header", path, fileCountsMagic)
+	}
+	if err := header.Check(opts.Normalizer); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	opts.warnGutenberg(path, header)
+
+	return header, files, nil
+}
+
+// Add merges one file's counts into the table, updating its document
+// frequencies and the file and token totals of its header
+func (t *BigramTable) Add(f *FileCounts) {
+	for bigram, count := range f.Counts {
+		t.Counts[bigram] += count
+		t.Total += count
+		if t.DocFreq != nil && count > 0 {
+			t.DocFreq[bigram]++
+		}
+	}
+	if t.Header != nil {
+		t.Header.Files = addCounts(t.Header.Files, 1)
+		t.Header.Tokens = addCounts(t.Header.Tokens, f.Tokens)
+	}
+}
+
+// Without returns a copy of the table with one file's counts removed, as if
+// the file had never been counted. Per-file counts are token counts, so the
+// table must count tokens too (see TableHeader.CheckTokens).
+func (t *BigramTable) Without(f *FileCounts) *BigramTable {
+	result := &BigramTable{
+		Counts: make(map[string]int, len(t.Counts)),
+		Total:  t.Total,
+	}
+	for bigram, count := range t.Counts {
+		result.Counts[bigram] = count
+	}
+	if t.Header != nil {
+		header := *t.Header
+		header.Files = subtractCounts(header.Files, 1)
+		header.Tokens = subtractCounts(header.Tokens, f.Tokens)
+		result.Header = &header
+	}
+	if t.DocFreq != nil {
+		result.DocFreq = make(map[string]int, len(t.DocFreq))
+		for bigram, df := range t.DocFreq {
+			result.DocFreq[bigram] = df
+		}
+	}
+
+	for bigram, count := range f.Counts {
+		removed := min(count, result.Counts[bigram])
+		result.Counts[bigram] -= removed
+		result.Total -= removed
+		if result.Counts[bigram] == 0 {
+			delete(result.Counts, bigram)
+		}
+		if result.DocFreq != nil && count > 0 && result.DocFreq[bigram] > 0 {
+			result.DocFreq[bigram]--
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,220 @@
+package penkata
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// gutenbergStart matches the lines after which the body of a Project
+	// Gutenberg text begins: the start marker, or the end of the old small print
+	gutenbergStart = regexp.MustCompile(`(?i)^\s*(\*{3}\s*START OF (THE|THIS) PROJECT GUTENBERG|\*END\*\s*THE SMALL PRINT)`)
+
+	// gutenbergEnd matches the lines before which the body ends: the end
+	// marker or the old "End of the Project Gutenberg EBook" line
+	gutenbergEnd = regexp.MustCompile(`(?i)^\s*(\*{3}\s*END OF (THE|THIS) PROJECT GUTENBERG|END OF (THE )?PROJECT GUTENBERG'?S? )`)
+
+	// gutenbergNote matches the first line of a transcriber's note
+	gutenbergNote = regexp.MustCompile(`(?i)^\s*\[?\s*TRANSCRIBER['’]?S?['’]?\s+NOTES?\b`)
+
+	// gutenbergCredit matches the first line of the production credits that
+	// often open the body
+	gutenbergCredit = regexp.MustCompile(`(?i)^\s*(PRODUCED|TRANSCRIBED|E-?TEXT PREPARED) BY\b`)
+)
+
+// maxHeaderLines bounds how far into a text start markers are looked for. The
+// lines after the last start marker in this range are held back until it has
+// been read, as older texts have a start marker before and after their small
+// print.
+const maxHeaderLines = 1000
+
+// maxNoteLines bounds how far ahead the closing bracket of a transcriber's
+// note is looked for
+const maxNoteLines = 200
+
+// OpenText opens a text file for counting or scoring. With gutenberg set, only
+// the body of a Project Gutenberg text is read (see StripGutenberg).
+func OpenText(path string, gutenberg bool) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil || !gutenberg {
+		return file, err
+	}
+	return &textFile{Reader: StripGutenberg(file), Closer: file}, nil
+}
+
+// textFile reads a filtered file and closes the file itself
+type textFile struct {
+	io.Reader
+	io.Closer
+}
+
+// StripGutenberg returns a reader of the body of a Project Gutenberg text,
+// without the header before its start marker, the license after its end
+// marker, production credits and transcriber's notes. Both the current "***
+// START OF THE PROJECT GUTENBERG EBOOK" markers and the older small print
+// variants are recognized. Text without a start or end marker is kept from
+// its beginning or to its end; an end marker before the start marker is
+// ignored. The text is filtered line by line as it is read.
+func StripGutenberg(r io.Reader) io.Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &gutenbergReader{body: gutenbergBody{scanner: scanner}, opening: true}
+}
+
+// gutenbergBody yields the lines of a text between its start and end markers
+type gutenbergBody struct {
+	scanner *bufio.Scanner
+	ahead   []string // Lines after the last start marker in the header range, yielded first
+	started bool     // Whether the header range has been read
+	done    bool     // Whether the end of the body has been reached
+}
+
+// next returns the next line of the body, or false at its end
+func (b *gutenbergBody) next() (string, bool) {
+	if !b.started {
+		b.started = true
+		b.readHeader()
+	}
+	if len(b.ahead) > 0 {
+		line := b.ahead[0]
+		b.ahead = b.ahead[1:]
+		return line, true
+	}
+	if b.done || !b.scanner.Scan() {
+		return "", false
+	}
+	if line := b.scanner.Text(); !gutenbergEnd.MatchString(line) {
+		return line, true
+	}
+	b.done = true
+	return "", false
+}
+
+// readHeader reads the header range, keeping the lines after its last start
+// marker. An end marker only ends the body after a start marker, or if no
+// start marker follows it in the range.
+func (b *gutenbergBody) readHeader() {
+	marked := false // Whether a start marker was seen
+	end := -1       // Index in ahead of an end marker before any start marker
+	for n := 0; n < maxHeaderLines && b.scanner.Scan(); n++ {
+		line := b.scanner.Text()
+		switch {
+		case gutenbergStart.MatchString(line):
+			b.ahead, marked, end = b.ahead[:0], true, -1
+		case gutenbergEnd.MatchString(line) && marked:
+			b.done = true
+			return
+		case gutenbergEnd.MatchString(line) && end < 0:
+			end = len(b.ahead)
+			b.ahead = append(b.ahead, line)
+		default:
+			b.ahead = append(b.ahead, line)
+		}
+	}
+	if end >= 0 {
+		b.ahead = b.ahead[:end]
+		b.done = true
+	}
+}
+
+// gutenbergReader drops production credits and transcriber's notes from the
+// lines of a body
+type gutenbergReader struct {
+	body    gutenbergBody
+	opening bool     // Production credits are only stripped before the text proper
+	pending []string // Lines read ahead of a note that are filtered next
+	out     []byte   // Filtered text not yet read
+}
+
+func (r *gutenbergReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		line, ok := r.nextLine()
+		if !ok {
+			if err := r.body.scanner.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		r.out = append(append(r.out, line...), '\n')
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// next returns the next line read ahead, or else of the body
+func (r *gutenbergReader) next() (string, bool) {
+	if len(r.pending) > 0 {
+		line := r.pending[0]
+		r.pending = r.pending[1:]
+		return line, true
+	}
+	return r.body.next()
+}
+
+// nextLine returns the next line that is not part of a credit or a note
+func (r *gutenbergReader) nextLine() (string, bool) {
+	for {
+		line, ok := r.next()
+		if !ok {
+			return "", false
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			return line, true
+		case r.opening && gutenbergCredit.MatchString(line):
+			r.skipParagraph()
+		case gutenbergNote.MatchString(line) && strings.HasPrefix(trimmed, "["):
+			r.skipBracketed(line)
+		case gutenbergNote.MatchString(line):
+			r.skipParagraph()
+		default:
+			r.opening = false
+			return line, true
+		}
+	}
+}
+
+// skipParagraph skips the rest of the paragraph whose first line was read
+func (r *gutenbergReader) skipParagraph() {
+	for {
+		line, ok := r.next()
+		if !ok {
+			return
+		}
+		if strings.TrimSpace(line) == "" {
+			r.pending = append([]string{line}, r.pending...)
+			return
+		}
+	}
+}
+
+// skipBracketed skips a bracketed note up to the line closing its bracket,
+// which may be several paragraphs later. A note not closed within
+// maxNoteLines ends at its first blank line.
+func (r *gutenbergReader) skipBracketed(first string) {
+	if strings.Contains(first, "]") {
+		return
+	}
+	var ahead []string
+	for len(ahead) < maxNoteLines {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		if strings.Contains(line, "]") {
+			return
+		}
+		ahead = append(ahead, line)
+	}
+	for i, line := range ahead {
+		if strings.TrimSpace(line) == "" {
+			r.pending = append(ahead[i:], r.pending...)
+			return
+		}
+	}
+}
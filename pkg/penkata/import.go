@@ -0,0 +1,220 @@
+package penkata
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formats of external letter-pair frequency tables
+const (
+	ImportNorvig = "norvig" // "pair<TAB>count" lines, as in Norvig's count_2l.txt
+	ImportCSV    = "csv"    // Pair and count columns, e.g. derived from Google Books n-grams
+	ImportJSON   = "json"   // An object mapping pairs to counts
+)
+
+// DefaultImportBoundary holds the characters that commonly mark a word
+// boundary in external tables
+const DefaultImportBoundary = " _^$"
+
+// ImportOptions controls how an external frequency table is converted
+type ImportOptions struct {
+	Format   string // ImportNorvig, ImportCSV or ImportJSON; detected from the extension if empty
+	Boundary string // Characters mapped onto the '_' word boundary
+	FoldCase bool   // Lowercase pairs, summing the counts of pairs that differ in case
+	Column   int    // 1-based column of the counts in CSV files (default 2)
+	Strict   bool   // Reject entries that are not bigrams instead of skipping them
+}
+
+// DetectImportFormat guesses the format of an external table from its extension
+func DetectImportFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ImportCSV
+	case ".json":
+		return ImportJSON
+	default:
+		return ImportNorvig
+	}
+}
+
+// ImportBigramTable converts an external letter-pair frequency table into a
+// count table. Boundary characters are mapped onto '_' as in
+// extractBigramsFromWord. The header records the normalization as
+// ImportedNormalization and the numbers of files and tokens as UnknownCount.
+// It also returns the number of entries skipped because they were not bigrams.
+func ImportBigramTable(path string, opts ImportOptions) (*BigramTable, int, error) {
+	if opts.Format == "" {
+		opts.Format = DetectImportFormat(path)
+	}
+	if opts.Column == 0 {
+		opts.Column = 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	header := NewTableHeader(path, Normalizer{FoldCase: opts.FoldCase})
+	header.Normalization = ImportedNormalization
+	header.Files, header.Tokens = UnknownCount, UnknownCount
+	table := &BigramTable{
+		Header: header,
+		Counts: make(map[string]int),
+	}
+	skipped := 0
+	add := func(where, key string, count int) error {
+		bigram, ok := opts.bigram(key)
+		if !ok {
+			if opts.Strict {
+				return fmt.Errorf("%s: %q is not a bigram", where, key)
+			}
+			skipped++
+			return nil
+		}
+		table.Counts[bigram] += count
+		table.Total += count
+		return nil
+	}
+
+	switch opts.Format {
+	case ImportNorvig:
+		err = importNorvig(file, path, add)
+	case ImportCSV:
+		err = importCSV(file, path, opts.Column, add)
+	case ImportJSON:
+		err = importJSON(file, path, add)
+	default:
+		err = fmt.Errorf("unknown import format %q (expected %s, %s or %s)", opts.Format, ImportNorvig, ImportCSV, ImportJSON)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return table, skipped, nil
+}
+
+// bigram maps an external pair onto penkata's bigram convention
+func (opts ImportOptions) bigram(key string) (string, bool) {
+	if opts.FoldCase {
+		key = strings.ToLower(key)
+	}
+	key = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(opts.Boundary, r) {
+			return '_'
+		}
+		return r
+	}, key)
+	if utf8.RuneCountInString(key) != 2 || key == "__" {
+		return "", false
+	}
+	return key, true
+}
+
+// importNorvig reads "pair<TAB>count" lines. Pairs may contain spaces, so
+// the count is taken after the last tab, or the last space if there is none.
+func importNorvig(r io.Reader, path string, add func(where, key string, count int) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		where := fmt.Sprintf("%s:%d", path, lineNum)
+		i := strings.LastIndexByte(line, '\t')
+		if i < 0 {
+			i = strings.LastIndexByte(line, ' ')
+		}
+		if i < 0 {
+			return fmt.Errorf("%s: expected pair and count", where)
+		}
+		count, err := parseImportCount(line[i+1:])
+		if err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		if err := add(where, line[:i], count); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// importCSV reads the pair from the first column and the count from the
+// given column. A first row without a numeric count is taken as a header.
+func importCSV(r io.Reader, path string, column int, add func(where, key string, count int) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	for rowNum := 1; ; rowNum++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		where := fmt.Sprintf("%s:%d", path, rowNum)
+		if len(record) < column {
+			return fmt.Errorf("%s: expected at least %d columns", where, column)
+		}
+		count, err := parseImportCount(record[column-1])
+		if err != nil {
+			if rowNum == 1 {
+				continue
+			}
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		if err := add(where, record[0], count); err != nil {
+			return err
+		}
+	}
+}
+
+// importJSON reads an object mapping pairs to counts
+func importJSON(r io.Reader, path string, add func(where, key string, count int) error) error {
+	var entries map[string]json.Number
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&entries); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	for key, value := range entries {
+		count, err := parseImportCount(value.String())
+		if err != nil {
+			return fmt.Errorf("%s: %q: %w", path, key, err)
+		}
+		if err := add(path, key, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseImportCount parses a non-negative count, rounding fractional values
+func parseImportCount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if count, err := strconv.Atoi(s); err == nil && count >= 0 {
+		return count, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || f > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid count %q", s)
+	}
+	return int(math.Round(f)), nil
+}
@@ -0,0 +1,185 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// joinAnyLetter matches any letter in a join rule
+const joinAnyLetter = "*"
+
+// JoinRule assigns a join type to the bigrams leaving one set of letters and
+// entering another
+type JoinRule struct {
+	Type string // Join type, e.g. "baseline"
+	From string // Letters the join leaves, or "*" for any letter
+	To   string // Letters the join enters, or "*" for any letter
+}
+
+// matches reports whether the rule covers a join from x to y
+func (r JoinRule) matches(x, y rune) bool {
+	return (r.From == joinAnyLetter || strings.ContainsRune(r.From, x)) &&
+		(r.To == joinAnyLetter || strings.ContainsRune(r.To, y))
+}
+
+// JoinStyle classifies the joins of a connected script. The first matching
+// rule determines a bigram's join type.
+type JoinStyle struct {
+	Name  string // Style or source file
+	Rules []JoinRule
+
+	types []string // Distinct join types, computed when the style is created
+}
+
+// NewJoinStyle creates a join style from its rules
+func NewJoinStyle(name string, rules []JoinRule) *JoinStyle {
+	s := &JoinStyle{Name: name, Rules: rules}
+	s.types = s.ruleTypes()
+	return s
+}
+
+// DefaultJoinStyle returns the join rules of a standard italic hand: no join
+// after letters that end away from the baseline, high joins out of o, v and
+// w, joins into round letters, and baseline joins everywhere else.
+func DefaultJoinStyle() *JoinStyle {
+	return NewJoinStyle("italic", []JoinRule{
+		{Type: "none", From: "bfgjpqsxyz", To: joinAnyLetter},
+		{Type: "high", From: "ovw", To: joinAnyLetter},
+		{Type: "round", From: joinAnyLetter, To: "acdgoq"},
+		{Type: "baseline", From: joinAnyLetter, To: joinAnyLetter},
+	})
+}
+
+// LoadJoinStyle reads a join style with one "type<TAB>from<TAB>to" rule per
+// line, where from and to are sets of lowercase letters or "*" for any
+// letter. Blank lines and lines starting with '#' are ignored.
+func LoadJoinStyle(path string) (*JoinStyle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []JoinRule
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("%s:%d: expected join type, from letters and to letters", path, lineNum)
+		}
+		rules = append(rules, JoinRule{Type: parts[0], From: parts[1], To: parts[2]})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s: no join rules", path)
+	}
+
+	return NewJoinStyle(path, rules), nil
+}
+
+// Types returns the distinct join types in rule order
+func (s *JoinStyle) Types() []string {
+	if s.types != nil {
+		return s.types
+	}
+	return s.ruleTypes()
+}
+
+// ruleTypes collects the distinct join types of the rules in rule order
+func (s *JoinStyle) ruleTypes() []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, r := range s.Rules {
+		if !seen[r.Type] {
+			seen[r.Type] = true
+			types = append(types, r.Type)
+		}
+	}
+	return types
+}
+
+// Classify returns the join type of a bigram, or "" if the bigram is not a
+// join between two letters (e.g. a word boundary or punctuation)
+func (s *JoinStyle) Classify(bigram string) string {
+	runes := []rune(strings.ToLower(bigram))
+	if len(runes) != 2 || !unicode.IsLetter(runes[0]) || !unicode.IsLetter(runes[1]) {
+		return ""
+	}
+	for _, r := range s.Rules {
+		if r.matches(runes[0], runes[1]) {
+			return r.Type
+		}
+	}
+	return ""
+}
+
+// CountJoins aggregates bigram counts by join type
+func (s *JoinStyle) CountJoins(counts map[string]int) map[string]int {
+	joins := make(map[string]int)
+	for bigram, count := range counts {
+		if t := s.Classify(bigram); t != "" {
+			joins[t] += count
+		}
+	}
+	return joins
+}
+
+// JoinCoverageScorer rewards windows that contain every join type of a style
+type JoinCoverageScorer struct {
+	Style  *JoinStyle
+	Weight float64 // Strength λ in [0, 1]
+}
+
+// Features returns the join types of a word's bigrams
+func (s *JoinCoverageScorer) Features(word string, bigrams []string) []string {
+	var types []string
+	for _, bigram := range bigrams {
+		if t := s.Style.Classify(bigram); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Score scales the score by (1-λ) + λ·coverage, where coverage is the
+// fraction of join types present in the window
+func (s *JoinCoverageScorer) Score(w *Window, score float64) float64 {
+	covered, total := s.coverage(w)
+	return score * (1 - s.Weight + s.Weight*float64(covered)/float64(total))
+}
+
+// Columns returns the name of the reported coverage column
+func (s *JoinCoverageScorer) Columns() []string {
+	return []string{"joins"}
+}
+
+// Report returns the covered and total number of join types
+func (s *JoinCoverageScorer) Report(w *Window) []string {
+	covered, total := s.coverage(w)
+	return []string{fmt.Sprintf("%d/%d", covered, total)}
+}
+
+// coverage returns the number of join types present in the window and the
+// number of join types in the style
+func (s *JoinCoverageScorer) coverage(w *Window) (int, int) {
+	types := s.Style.Types()
+	covered := 0
+	for _, t := range types {
+		if w.FeatureCount(s, t) > 0 {
+			covered++
+		}
+	}
+	return covered, len(types)
+}
@@ -0,0 +1,231 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Transitions between the keys of a bigram, from easiest to hardest
+const (
+	KeyAlternate  = "alternate"   // Keys typed by different hands
+	KeyRepeat     = "repeat"      // The same key twice
+	KeySameHand   = "same-hand"   // Different fingers of the same hand
+	KeySameFinger = "same-finger" // Different keys typed by the same finger
+)
+
+// transitionCosts holds the typing difficulty of each transition
+var transitionCosts = map[string]float64{KeyAlternate: 0, KeyRepeat: 0.5, KeySameHand: 1, KeySameFinger: 3}
+
+// Key is the position of a character on a keyboard
+type Key struct {
+	Row    int // 0 is the top letter row
+	Finger int // 0-3 for the left pinky to index, 4-7 for the right index to pinky
+}
+
+// Hand returns 0 for keys typed by the left hand and 1 for the right hand
+func (k Key) Hand() int {
+	return k.Finger / 4
+}
+
+// KeyboardLayout maps characters to the keys that type them
+type KeyboardLayout struct {
+	Name string // Built-in layout or source file
+	Keys map[rune]Key
+}
+
+// builtinLayouts holds the letter rows of the built-in layouts
+var builtinLayouts = map[string][]string{
+	"qwerty":  {"qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./"},
+	"dvorak":  {"',.pyfgcrl/=", "aoeuidhtns-", ";qjkxbmwvz"},
+	"colemak": {"qwfpgjluy;[]", "arstdhneio'", "zxcvbkm,./"},
+}
+
+// columnFingers assigns the columns of a row to fingers in touch typing:
+// the index fingers cover two columns each and the right pinky everything
+// beyond the tenth column
+var columnFingers = []int{0, 1, 2, 3, 3, 4, 4, 5, 6, 7}
+
+// NewKeyboardLayout builds a layout from its rows, top row first. Characters
+// are assigned to fingers by column as in standard touch typing.
+func NewKeyboardLayout(name string, rows []string) *KeyboardLayout {
+	layout := &KeyboardLayout{Name: name, Keys: make(map[rune]Key)}
+	for row, keys := range rows {
+		col := 0
+		for _, r := range keys {
+			finger := columnFingers[min(col, len(columnFingers)-1)]
+			layout.Keys[unicode.ToLower(r)] = Key{Row: row, Finger: finger}
+			col++
+		}
+	}
+	return layout
+}
+
+// KeyboardLayoutNames returns the names of the built-in layouts
+func KeyboardLayoutNames() []string {
+	return []string{"qwerty", "dvorak", "colemak"}
+}
+
+// ParseKeyboardLayout returns the built-in layout with the given name, or
+// loads the layout from a file otherwise
+func ParseKeyboardLayout(spec string) (*KeyboardLayout, error) {
+	if rows, ok := builtinLayouts[spec]; ok {
+		return NewKeyboardLayout(spec, rows), nil
+	}
+	return LoadKeyboardLayout(spec)
+}
+
+// LoadKeyboardLayout reads a layout with one row of keys per line, top row
+// first, e.g. "qwertyuiop". Blank lines and lines starting with '#' are ignored.
+func LoadKeyboardLayout(path string) (*KeyboardLayout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no key rows", path)
+	}
+
+	return NewKeyboardLayout(path, rows), nil
+}
+
+// Transition classifies how the keys of a bigram are typed and reports whether
+// the same hand jumps over the home row. It returns ok false if the bigram
+// includes a character not on the layout, such as the word boundary.
+func (l *KeyboardLayout) Transition(bigram string) (transition string, rowJump bool, ok bool) {
+	runes := []rune(strings.ToLower(bigram))
+	if len(runes) != 2 {
+		return "", false, false
+	}
+	x, okX := l.Keys[runes[0]]
+	y, okY := l.Keys[runes[1]]
+	if !okX || !okY {
+		return "", false, false
+	}
+
+	switch {
+	case x.Hand() != y.Hand():
+		return KeyAlternate, false, true
+	case runes[0] == runes[1]:
+		return KeyRepeat, false, true
+	}
+	rowJump = abs(x.Row-y.Row) > 1
+	if x.Finger == y.Finger {
+		return KeySameFinger, rowJump, true
+	}
+	return KeySameHand, rowJump, true
+}
+
+// Cost returns the typing difficulty of a bigram: 0 for alternating hands,
+// 0.5 for a repeated key, 1 within one hand and 3 for the same finger, plus 1
+// for a row jump. Bigrams not on the layout cost nothing.
+func (l *KeyboardLayout) Cost(bigram string) float64 {
+	transition, rowJump, ok := l.Transition(bigram)
+	if !ok {
+		return 0
+	}
+	cost := transitionCosts[transition]
+	if rowJump {
+		cost++
+	}
+	return cost
+}
+
+// abs returns the absolute value of an integer
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// kbdTransform weights bigrams by typing difficulty: kbd:LAYOUT[:λ] multiplies
+// each weight by (1+λc)/(1+λc̄), where c is the bigram's cost on the layout
+// and c̄ the mean cost. LAYOUT is qwerty, dvorak, colemak or a layout file
+// whose path cannot contain ':' or '+', and λ defaults to 1.
+func kbdTransform(args []string) (WeightTransform, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected layout (%s or a file)", strings.Join(KeyboardLayoutNames(), ", "))
+	}
+	layout, err := ParseKeyboardLayout(args[0])
+	if err != nil {
+		return nil, err
+	}
+	lambda := 1.0
+	spec := "kbd:" + args[0]
+	if len(args) > 1 {
+		v, err := parseFloatArgs(args[1:], 1, 1)
+		if err != nil {
+			return nil, fmt.Errorf("%w (the layout path cannot contain ':' or '+')", err)
+		}
+		lambda = v[0]
+		spec = transformSpec(spec, lambda)
+	}
+
+	return NewTransform(spec, func(weights map[string]float64) {
+		if len(weights) == 0 {
+			return
+		}
+		costs := make(map[string]float64, len(weights))
+		meanCost := 0.0
+		for bigram := range weights {
+			costs[bigram] = layout.Cost(bigram)
+			meanCost += costs[bigram]
+		}
+		meanCost /= float64(len(weights))
+
+		for bigram, w := range weights {
+			weights[bigram] = w * (1 + lambda*costs[bigram]) / (1 + lambda*meanCost)
+		}
+	}), nil
+}
+
+// KeyboardReporter reports the key transitions in each window
+type KeyboardReporter struct {
+	Layout *KeyboardLayout
+}
+
+// Columns returns the names of the transition count columns
+func (s *KeyboardReporter) Columns() []string {
+	return []string{KeySameFinger, KeySameHand, "row-jump", KeyAlternate}
+}
+
+// Report returns the number of same-finger, same-hand, row-jump and
+// alternating bigrams typed in the window
+func (s *KeyboardReporter) Report(w *Window) []string {
+	counts := make(map[string]int)
+	rowJumps := 0
+	for bigram, count := range w.bigrams {
+		transition, rowJump, ok := s.Layout.Transition(bigram)
+		if !ok {
+			continue
+		}
+		counts[transition] += count
+		if rowJump {
+			rowJumps += count
+		}
+	}
+	return []string{
+		fmt.Sprint(counts[KeySameFinger]),
+		fmt.Sprint(counts[KeySameHand]),
+		fmt.Sprint(rowJumps),
+		fmt.Sprint(counts[KeyAlternate]),
+	}
+}
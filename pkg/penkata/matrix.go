@@ -0,0 +1,68 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteMatrix writes per-file counts as a sparse document–bigram matrix in
+// Matrix Market coordinate format, with one row per file and one column per
+// bigram. The row index lists "path<TAB>tokens" and the column index
+// "bigram<TAB>count" lines, where line i names row or column i. Files are
+// ordered by path and bigrams by descending total count.
+func WriteMatrix(matrix, rows, columns io.Writer, header *TableHeader, files []*FileCounts) error {
+	sorted := make([]*FileCounts, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	total := &BigramTable{Counts: make(map[string]int)}
+	for _, f := range sorted {
+		total.Add(f)
+	}
+	bigrams := total.SortedBigrams()
+	column := make(map[string]int, len(bigrams))
+	for i, bigram := range bigrams {
+		column[bigram] = i + 1
+	}
+
+	// Row and column indexes
+	rw := bufio.NewWriter(rows)
+	for _, f := range sorted {
+		fmt.Fprintf(rw, "%s\t%d\n", f.Path, f.Tokens)
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	cw := bufio.NewWriter(columns)
+	for _, bigram := range bigrams {
+		fmt.Fprintf(cw, "%s\t%d\n", bigram, total.Counts[bigram])
+	}
+	if err := cw.Flush(); err != nil {
+		return err
+	}
+
+	// Matrix entries, ordered by row and then column
+	nonzero := 0
+	for _, f := range sorted {
+		nonzero += len(f.Counts)
+	}
+	mw := bufio.NewWriter(matrix)
+	fmt.Fprintln(mw, "%%MatrixMarket matrix coordinate integer general")
+	for _, f := range header.fields(fileCountsMagic) {
+		fmt.Fprintf(mw, "%% %s\t%s\n", f[0], f[1])
+	}
+	fmt.Fprintf(mw, "%d %d %d\n", len(sorted), len(bigrams), nonzero)
+	for i, f := range sorted {
+		cols := make([]int, 0, len(f.Counts))
+		for bigram := range f.Counts {
+			cols = append(cols, column[bigram])
+		}
+		sort.Ints(cols)
+		for _, j := range cols {
+			fmt.Fprintf(mw, "%d %d %d\n", i+1, j, f.Counts[bigrams[j-1]])
+		}
+	}
+	return mw.Flush()
+}
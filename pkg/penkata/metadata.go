@@ -0,0 +1,355 @@
+package penkata
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxMetadataLines bounds how far into a text without a start marker its
+// header is searched for metadata
+const maxMetadataLines = 500
+
+var (
+	// metadataField matches a "Field: value" header line of a Gutenberg text
+	metadataField = regexp.MustCompile(`(?i)^(title|authors?|translators?|release date|posting date|original publication)\s*:\s*(.*)$`)
+
+	// ebookNumber matches the ebook number of a Gutenberg text, e.g. "[eBook #1041]"
+	ebookNumber = regexp.MustCompile(`(?i)\bE-?(?:BOOK|TEXT)\s*#\s*(\d+)`)
+
+	// ebookPath matches the file names of Gutenberg texts, e.g. "pg1041.txt" or "47746-8.txt"
+	ebookPath = regexp.MustCompile(`^(?:pg)?(\d+)(?:-\d+)?\.txt$`)
+
+	// yearPattern matches a plausible publication year
+	yearPattern = regexp.MustCompile(`\b(1[0-9]|20)\d\d\b`)
+)
+
+// BookMetadata describes a Project Gutenberg text for attribution
+type BookMetadata struct {
+	EBook       int      // Ebook number, 0 if unknown
+	Title       string   // Title, with any subtitle
+	Authors     []string // Authors as given, "First Last" or "Last, First"
+	Translators []string // Translators, in the same forms
+	Released    string   // Release date on Project Gutenberg, as given
+	Published   int      // Year of original publication, 0 if unknown
+}
+
+// Year returns the year of original publication, or 0 if it is unknown. The
+// release year is not a substitute, as Project Gutenberg released most texts
+// long after they were published.
+func (m *BookMetadata) Year() int {
+	return m.Published
+}
+
+// ReleaseYear returns the year the text was released on Project Gutenberg, or
+// 0 if it is unknown
+func (m *BookMetadata) ReleaseYear() int {
+	// Skip the ebook number of release dates like "September 1, 1997 [eBook #1041]"
+	released, _, _ := strings.Cut(m.Released, "[")
+	year, _ := strconv.Atoi(yearPattern.FindString(released))
+	return year
+}
+
+// Author returns the authors formatted for a citation, e.g. "Johnson, Rossiter"
+func (m *BookMetadata) Author() string {
+	return citationNames(m.Authors)
+}
+
+// Citation formats the metadata as a reference, e.g. "Johnson, Rossiter.
+// (1894). Campfire and Battlefield. (Translated by Montgomery, W.)", leaving
+// out what is unknown
+func (m *BookMetadata) Citation() string {
+	var parts []string
+	if author := m.Author(); author != "" {
+		parts = append(parts, withPeriod(author))
+	}
+	if year := m.Year(); year > 0 {
+		parts = append(parts, fmt.Sprintf("(%d).", year))
+	} else {
+		parts = append(parts, "(n.d.).")
+	}
+	if m.Title != "" {
+		parts = append(parts, withPeriod(m.Title))
+	}
+	if len(m.Translators) > 0 {
+		parts = append(parts, "(Translated by "+withPeriod(citationNames(m.Translators))+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// merge fills the fields of m that are unknown from other
+func (m *BookMetadata) merge(other *BookMetadata) {
+	if m.EBook == 0 {
+		m.EBook = other.EBook
+	}
+	if m.Title == "" {
+		m.Title = other.Title
+	}
+	if len(m.Authors) == 0 {
+		m.Authors = other.Authors
+	}
+	if len(m.Translators) == 0 {
+		m.Translators = other.Translators
+	}
+	if m.Released == "" {
+		m.Released = other.Released
+	}
+	if m.Published == 0 {
+		m.Published = other.Published
+	}
+}
+
+// ParseGutenbergMetadata reads the Title, Author, Translator, Release Date and
+// Original publication fields and the ebook number from the header of a
+// Project Gutenberg text, which ends at its start marker. Values continued on
+// indented lines are joined with a space.
+func ParseGutenbergMetadata(r io.Reader) (*BookMetadata, error) {
+	m := &BookMetadata{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var field *string // Value continued by indented lines
+	setField := func(name, value string) {
+		field = nil
+		switch strings.ToLower(name) {
+		case "title":
+			m.Title = value
+			field = &m.Title
+		case "author", "authors":
+			m.Authors = splitNames(value)
+		case "translator", "translators":
+			m.Translators = splitNames(value)
+		case "release date", "posting date":
+			if m.Released == "" {
+				m.Released = value
+			}
+		case "original publication":
+			if years := yearPattern.FindAllString(value, -1); len(years) > 0 {
+				m.Published, _ = strconv.Atoi(years[len(years)-1])
+			}
+		}
+	}
+
+	for lineNum := 1; scanner.Scan() && lineNum <= maxMetadataLines; lineNum++ {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if gutenbergStart.MatchString(line) {
+			break
+		}
+		if m.EBook == 0 {
+			if match := ebookNumber.FindStringSubmatch(line); match != nil {
+				m.EBook, _ = strconv.Atoi(match[1])
+			}
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			field = nil
+		case field != nil && line != trimmed:
+			*field += " " + trimmed
+		default:
+			field = nil
+			if match := metadataField.FindStringSubmatch(trimmed); match != nil {
+				setField(match[1], strings.TrimSpace(match[2]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadGutenbergMetadata reads the metadata in the header of a Project
+// Gutenberg text file. The ebook number is taken from the file name if the
+// header does not give it.
+func ReadGutenbergMetadata(path string) (*BookMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m, err := ParseGutenbergMetadata(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if m.EBook == 0 {
+		m.EBook = EBookFromPath(path)
+	}
+	return m, nil
+}
+
+// EBookFromPath returns the ebook number in the name of a Gutenberg text
+// file, e.g. 47746 for "gutenberg/4/7/7/4/47746/47746-8.txt", or 0
+func EBookFromPath(path string) int {
+	match := ebookPath.FindStringSubmatch(strings.ToLower(filepath.Base(path)))
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
+
+// Catalog maps ebook numbers to metadata from the Project Gutenberg catalog
+type Catalog map[int]*BookMetadata
+
+// LoadCatalog reads the offline Project Gutenberg catalog, pg_catalog.csv,
+// with Text#, Issued, Title and Authors columns. A title's subtitle follows a
+// newline and is joined with a colon. Authors are separated by
+// semicolons, with life dates and roles such as "[Translator]"; contributors
+// in other roles than author and translator are ignored.
+func LoadCatalog(path string) (Catalog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	index := make(map[string]int, len(columns))
+	for i, name := range columns {
+		index[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+	for _, name := range []string{"Text#", "Issued", "Title", "Authors"} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%s: missing %s column", path, name)
+		}
+	}
+
+	catalog := make(Catalog)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		get := func(name string) string {
+			if i := index[name]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		n, err := strconv.Atoi(get("Text#"))
+		if err != nil {
+			continue
+		}
+		m := &BookMetadata{EBook: n, Released: get("Issued")}
+		lines := strings.Split(strings.ReplaceAll(get("Title"), "\r", ""), "\n")
+		m.Title = strings.TrimSpace(lines[0])
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				m.Title += ": " + line
+			}
+		}
+		for _, name := range strings.Split(get("Authors"), ";") {
+			name, role, _ := strings.Cut(strings.TrimSpace(name), "[")
+			name = stripLifeDates(name)
+			switch {
+			case name == "":
+			case role == "":
+				m.Authors = append(m.Authors, name)
+			case strings.HasPrefix(strings.ToLower(role), "translator"):
+				m.Translators = append(m.Translators, name)
+			}
+		}
+		catalog[n] = m
+	}
+	return catalog, nil
+}
+
+// LookupBook returns the metadata of a text file from its Gutenberg header,
+// with fields from the catalog entry of its ebook number taking precedence.
+// The catalog may be nil.
+func LookupBook(path string, catalog Catalog) (*BookMetadata, error) {
+	m, err := ReadGutenbergMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := catalog[m.EBook]; ok && m.EBook > 0 {
+		book := *entry
+		book.Published = m.Published
+		book.merge(m)
+		return &book, nil
+	}
+	return m, nil
+}
+
+// splitNames splits a header value listing several people, e.g. "Montgomery,
+// W. and McGrath, E.H." or "Jane Doe; John Doe"
+func splitNames(value string) []string {
+	var names []string
+	for _, part := range strings.Split(value, ";") {
+		for _, name := range strings.Split(part, " and ") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// stripLifeDates removes comma-separated life dates from a catalog name,
+// e.g. "Shakespeare, William, 1564-1616"
+func stripLifeDates(name string) string {
+	var kept []string
+	for _, part := range strings.Split(name, ",") {
+		if part = strings.TrimSpace(part); part != "" && !strings.ContainsAny(part, "0123456789") {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ", ")
+}
+
+// citationNames formats names as "Last, First", joined with commas and a
+// final "and"
+func citationNames(names []string) string {
+	inverted := make([]string, len(names))
+	for i, name := range names {
+		inverted[i] = invertName(name)
+	}
+	switch len(inverted) {
+	case 0:
+		return ""
+	case 1:
+		return inverted[0]
+	default:
+		return strings.Join(inverted[:len(inverted)-1], ", ") + ", and " + inverted[len(inverted)-1]
+	}
+}
+
+// invertName turns "Rossiter Johnson" into "Johnson, Rossiter", leaving names
+// that already have a comma or a single word unchanged
+func invertName(name string) string {
+	if strings.Contains(name, ",") {
+		return name
+	}
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return name
+	}
+	return words[len(words)-1] + ", " + strings.Join(words[:len(words)-1], " ")
+}
+
+// withPeriod appends a period unless s already ends with punctuation
+func withPeriod(s string) string {
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
@@ -0,0 +1,146 @@
+package penkata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// overlayWildcard matches any single character in an overlay pattern
+const overlayWildcard = '?'
+
+// OverlayRule changes the weight of the bigrams matching a pattern
+type OverlayRule struct {
+	Pattern  string  // Bigram, with '?' matching any character
+	Absolute bool    // Whether Value replaces the weight instead of multiplying it
+	Value    float64 // Multiplier or absolute weight
+}
+
+// Overlay holds per-bigram emphasis rules applied on top of transformed
+// weights, e.g. to emphasize the joins a particular learner struggles with
+type Overlay struct {
+	Path  string // Source file
+	Rules []OverlayRule
+}
+
+// LoadOverlay reads an overlay file with one "pattern<TAB>value" rule per line.
+// A value of "*x" (or a bare number) multiplies the weight by x, "=x" sets it
+// to x. Blank lines and lines starting with '#' are ignored.
+func LoadOverlay(path string) (*Overlay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	overlay := &Overlay{Path: path}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected pattern and value separated by a tab", path, lineNum)
+		}
+		if utf8.RuneCountInString(pattern) != 2 {
+			return nil, fmt.Errorf("%s:%d: pattern %q must be two characters", path, lineNum, pattern)
+		}
+
+		rule := OverlayRule{Pattern: pattern}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, "="):
+			rule.Absolute = true
+			value = value[1:]
+		case strings.HasPrefix(value, "*"):
+			value = value[1:]
+		}
+		rule.Value, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid value %q", path, lineNum, value)
+		}
+
+		overlay.Rules = append(overlay.Rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return overlay, nil
+}
+
+// Matches reports whether a bigram matches the rule's pattern
+func (r OverlayRule) Matches(bigram string) bool {
+	return matchPattern(r.Pattern, bigram)
+}
+
+// isLiteral reports whether the pattern names a single bigram
+func (r OverlayRule) isLiteral() bool {
+	return !strings.ContainsRune(r.Pattern, overlayWildcard)
+}
+
+// matchPattern reports whether a bigram matches a pattern in which '?'
+// matches any single character
+func matchPattern(pattern, bigram string) bool {
+	p, b := []rune(pattern), []rune(bigram)
+	if len(p) != len(b) {
+		return false
+	}
+	for i := range p {
+		if p[i] != overlayWildcard && p[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply applies the rules in file order, so later rules override earlier
+// ones. Absolute weights for literal patterns are set even if the bigram has
+// no weight yet; wildcard patterns only affect bigrams that have one.
+func (o *Overlay) Apply(weights map[string]float64) {
+	for _, rule := range o.Rules {
+		if rule.isLiteral() {
+			if _, ok := weights[rule.Pattern]; ok || rule.Absolute {
+				weights[rule.Pattern] = rule.apply(weights[rule.Pattern])
+			}
+			continue
+		}
+		for bigram, w := range weights {
+			if rule.Matches(bigram) {
+				weights[bigram] = rule.apply(w)
+			}
+		}
+	}
+}
+
+// apply returns the weight after applying the rule
+func (r OverlayRule) apply(w float64) float64 {
+	if r.Absolute {
+		return r.Value
+	}
+	return w * r.Value
+}
+
+// Influences returns the patterns of the rules that match any of the given
+// bigrams, in file order
+func (o *Overlay) Influences(bigrams map[string]int) []string {
+	var patterns []string
+	for _, rule := range o.Rules {
+		for bigram, count := range bigrams {
+			if count > 0 && rule.Matches(bigram) {
+				patterns = append(patterns, rule.Pattern)
+				break
+			}
+		}
+	}
+	return patterns
+}
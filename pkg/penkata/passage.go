@@ -2,7 +2,6 @@ package penkata
 
 import (
 	"bufio"
-	"os"
 )
 
 // Passage represents a Window from a specific file
@@ -11,9 +10,10 @@ type Passage struct {
 	FilePath string // Source file path
 }
 
-// FindBestPassagesInFile finds the best passage for each window size in a file
-func FindBestPassagesInFile(filepath string, paramsList []*WindowParams) ([]Passage, error) {
-	file, err := os.Open(filepath)
+// FindBestPassagesInFile finds the best passage for each window size in a
+// file. With gutenberg set, only the body of a Project Gutenberg text is read.
+func FindBestPassagesInFile(filepath string, paramsList []*WindowParams, gutenberg bool) ([]Passage, error) {
+	file, err := OpenText(filepath, gutenberg)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,188 @@
+package penkata
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// DefaultSketchConfidence is the probability with which sketch error bounds hold
+const DefaultSketchConfidence = 0.99
+
+// CountSketch approximately counts bigrams in bounded memory. A count-min
+// sketch estimates the count of any bigram, never underestimating it, and the
+// bigrams with the largest estimates are tracked as heavy hitters, which
+// become the rows of the resulting table.
+type CountSketch struct {
+	width, depth int
+	cells        []uint64 // depth rows of width counters
+	total        int      // Sum of all counts added
+	heavy        *heavyHitters
+}
+
+// NewCountSketch creates a sketch using about budget bytes for its counters
+// and tracking the given number of heavy hitters. The number of rows is
+// chosen so that error bounds hold with DefaultSketchConfidence. The budget
+// covers the counters only, not the heavy hitters or the counts of the files
+// being added.
+func NewCountSketch(budget, heavyHitters int) (*CountSketch, error) {
+	depth := int(math.Ceil(math.Log(1 / (1 - DefaultSketchConfidence))))
+	width := budget / 8 / depth
+	if width < 1 {
+		return nil, fmt.Errorf("memory budget of %d bytes is too small for a %d-row sketch", budget, depth)
+	}
+	if heavyHitters < 1 {
+		return nil, fmt.Errorf("number of heavy hitters must be positive")
+	}
+	return &CountSketch{
+		width: width,
+		depth: depth,
+		cells: make([]uint64, width*depth),
+		heavy: newHeavyHitters(heavyHitters),
+	}, nil
+}
+
+// Add adds count occurrences of a bigram
+func (s *CountSketch) Add(bigram string, count int) {
+	estimate := uint64(math.MaxUint64)
+	h1, h2 := sketchHashes(bigram)
+	for row := 0; row < s.depth; row++ {
+		cell := &s.cells[row*s.width+s.column(h1, h2, row)]
+		*cell += uint64(count)
+		estimate = min(estimate, *cell)
+	}
+	s.total += count
+	s.heavy.offer(bigram, int(estimate))
+}
+
+// AddFile adds all counts of a file. The file's counts are collected in full
+// before they are added, so they take memory beyond the sketch's budget
+// until the file is done.
+func (s *CountSketch) AddFile(f *FileCounts) {
+	for bigram, count := range f.Counts {
+		s.Add(bigram, count)
+	}
+}
+
+// Estimate returns an upper bound on a bigram's count, which exceeds the true
+// count by at most ErrorBound with DefaultSketchConfidence
+func (s *CountSketch) Estimate(bigram string) int {
+	estimate := uint64(math.MaxUint64)
+	h1, h2 := sketchHashes(bigram)
+	for row := 0; row < s.depth; row++ {
+		estimate = min(estimate, s.cells[row*s.width+s.column(h1, h2, row)])
+	}
+	return int(estimate)
+}
+
+// Epsilon returns the relative error of the sketch: estimates exceed true
+// counts by at most Epsilon times the total count
+func (s *CountSketch) Epsilon() float64 {
+	return math.E / float64(s.width)
+}
+
+// ErrorBound returns the largest overestimate of any count, ε·N
+func (s *CountSketch) ErrorBound() int {
+	return int(math.Ceil(s.Epsilon() * float64(s.total)))
+}
+
+// Bytes returns the memory used by the sketch's counters
+func (s *CountSketch) Bytes() int {
+	return len(s.cells) * 8
+}
+
+// Dimensions returns the number of counters per row and the number of rows
+func (s *CountSketch) Dimensions() (width, depth int) {
+	return s.width, s.depth
+}
+
+// Table returns a table of the heavy hitters with their estimated counts.
+// Total holds the exact sum of all counts added, including bigrams that are
+// not heavy hitters.
+func (s *CountSketch) Table(header *TableHeader) *BigramTable {
+	table := &BigramTable{Header: header, Counts: make(map[string]int, len(s.heavy.items)), Total: s.total}
+	for _, item := range s.heavy.items {
+		table.Counts[item.bigram] = s.Estimate(item.bigram)
+	}
+	if header != nil {
+		header.ErrorBound = s.ErrorBound()
+		header.Confidence = DefaultSketchConfidence
+	}
+	return table
+}
+
+// column returns the counter of a row for a bigram, using double hashing
+func (s *CountSketch) column(h1, h2 uint64, row int) int {
+	return int((h1 + uint64(row)*h2) % uint64(s.width))
+}
+
+// sketchHashes returns two hashes of a bigram for double hashing
+func sketchHashes(bigram string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(bigram))
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+// heavyHitters tracks the bigrams with the largest estimated counts in a
+// min-heap, so that the smallest can be replaced when a larger one arrives
+type heavyHitters struct {
+	capacity int
+	items    []*heavyItem
+	index    map[string]*heavyItem
+}
+
+// heavyItem is a tracked bigram with its estimated count and heap position
+type heavyItem struct {
+	bigram   string
+	estimate int
+	pos      int
+}
+
+// newHeavyHitters creates a tracker for up to capacity bigrams
+func newHeavyHitters(capacity int) *heavyHitters {
+	return &heavyHitters{capacity: capacity, index: make(map[string]*heavyItem, capacity)}
+}
+
+// offer updates a bigram's estimate, tracking it if it is among the largest
+func (h *heavyHitters) offer(bigram string, estimate int) {
+	if item, ok := h.index[bigram]; ok {
+		item.estimate = estimate
+		heap.Fix(h, item.pos)
+		return
+	}
+	if len(h.items) < h.capacity {
+		heap.Push(h, &heavyItem{bigram: bigram, estimate: estimate})
+		return
+	}
+	if smallest := h.items[0]; estimate > smallest.estimate {
+		delete(h.index, smallest.bigram)
+		smallest.bigram, smallest.estimate = bigram, estimate
+		h.index[bigram] = smallest
+		heap.Fix(h, 0)
+	}
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface
+func (h *heavyHitters) Len() int           { return len(h.items) }
+func (h *heavyHitters) Less(i, j int) bool { return h.items[i].estimate < h.items[j].estimate }
+
+func (h *heavyHitters) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].pos, h.items[j].pos = i, j
+}
+
+func (h *heavyHitters) Push(x any) {
+	item := x.(*heavyItem)
+	item.pos = len(h.items)
+	h.items = append(h.items, item)
+	h.index[item.bigram] = item
+}
+
+func (h *heavyHitters) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, item.bigram)
+	return item
+}
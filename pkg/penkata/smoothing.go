@@ -0,0 +1,246 @@
+package penkata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Smoother estimates counts for rare and unseen bigrams before weights are
+// transformed, so that every bigram over the target alphabet gets a weight
+type Smoother interface {
+	// Smooth adjusts counts in place and adds every missing bigram over the alphabet
+	Smooth(counts map[string]float64, alphabet []rune)
+	// String returns the specification of the smoother
+	String() string
+}
+
+// ParseSmoothing parses a smoothing specification: "addk:K" (add-k),
+// "gt" (Good–Turing) or "interp:λ" (interpolation with a unigram-product backoff)
+func ParseSmoothing(spec string) (Smoother, error) {
+	name, arg, hasArg := strings.Cut(spec, ":")
+	param := func() (float64, error) {
+		if !hasArg {
+			return 0, fmt.Errorf("smoothing %q requires an argument", name)
+		}
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid smoothing argument %q", arg)
+		}
+		return v, nil
+	}
+
+	switch name {
+	case "addk":
+		k, err := param()
+		if err != nil {
+			return nil, err
+		}
+		if k <= 0 {
+			return nil, fmt.Errorf("add-k smoothing requires k > 0")
+		}
+		return addKSmoother(k), nil
+	case "gt":
+		if hasArg {
+			return nil, fmt.Errorf("smoothing %q takes no arguments", name)
+		}
+		return goodTuringSmoother{}, nil
+	case "interp":
+		lambda, err := param()
+		if err != nil {
+			return nil, err
+		}
+		if lambda < 0 || lambda > 1 {
+			return nil, fmt.Errorf("interpolation requires 0 <= λ <= 1")
+		}
+		return interpSmoother(lambda), nil
+	default:
+		return nil, fmt.Errorf("unknown smoothing %q (available: addk, gt, interp)", name)
+	}
+}
+
+// ParseAlphabet expands an alphabet specification such as "a-zA-Z'" into its
+// characters. A '-' between two characters denotes an inclusive range.
+func ParseAlphabet(spec string) ([]rune, error) {
+	runes := []rune(spec)
+	var alphabet []rune
+	for i := 0; i < len(runes); i++ {
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			lo, hi := runes[i], runes[i+2]
+			if lo > hi {
+				return nil, fmt.Errorf("invalid alphabet range %c-%c", lo, hi)
+			}
+			for r := lo; r <= hi; r++ {
+				alphabet = append(alphabet, r)
+			}
+			i += 2
+			continue
+		}
+		alphabet = append(alphabet, runes[i])
+	}
+	return uniqueRunes(alphabet), nil
+}
+
+// TableAlphabet returns the characters that occur in a table's bigrams,
+// excluding the word boundary marker
+func TableAlphabet(table *BigramTable) []rune {
+	var alphabet []rune
+	for bigram := range table.Counts {
+		alphabet = append(alphabet, []rune(bigram)...)
+	}
+	return uniqueRunes(alphabet)
+}
+
+// uniqueRunes returns the sorted distinct runes, excluding the word boundary marker
+func uniqueRunes(runes []rune) []rune {
+	seen := make(map[rune]bool, len(runes))
+	var result []rune
+	for _, r := range runes {
+		if r != '_' && !seen[r] {
+			seen[r] = true
+			result = append(result, r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// possibleBigrams returns every bigram over the alphabet, including the
+// leading and trailing word boundary bigrams
+func possibleBigrams(alphabet []rune) []string {
+	bigrams := make([]string, 0, len(alphabet)*(len(alphabet)+2))
+	for _, x := range alphabet {
+		bigrams = append(bigrams, "_"+string(x), string(x)+"_")
+		for _, y := range alphabet {
+			bigrams = append(bigrams, string([]rune{x, y}))
+		}
+	}
+	return bigrams
+}
+
+// addKSmoother adds a pseudo-count of k to every bigram
+type addKSmoother float64
+
+func (k addKSmoother) Smooth(counts map[string]float64, alphabet []rune) {
+	for bigram := range counts {
+		counts[bigram] += float64(k)
+	}
+	for _, bigram := range possibleBigrams(alphabet) {
+		if _, ok := counts[bigram]; !ok {
+			counts[bigram] = float64(k)
+		}
+	}
+}
+
+func (k addKSmoother) String() string {
+	return "addk:" + strconv.FormatFloat(float64(k), 'g', -1, 64)
+}
+
+// goodTuringMaxCount is the largest count adjusted by Good–Turing smoothing;
+// larger counts are considered reliable
+const goodTuringMaxCount = 5
+
+// goodTuringSmoother discounts small counts with Good–Turing estimates,
+// r* = (r+1)·N(r+1)/N(r), and shares the mass of singletons, N(1), equally
+// among unseen bigrams
+type goodTuringSmoother struct{}
+
+func (goodTuringSmoother) Smooth(counts map[string]float64, alphabet []rune) {
+	// Frequencies of frequencies
+	freqOfFreq := make(map[int]int)
+	total := 0.0
+	for _, c := range counts {
+		freqOfFreq[int(math.Round(c))]++
+		total += c
+	}
+
+	// Adjust small counts and track the mass of seen bigrams
+	seenMass := 0.0
+	for bigram, c := range counts {
+		r := int(math.Round(c))
+		if r > 0 && r < goodTuringMaxCount && freqOfFreq[r+1] > 0 {
+			counts[bigram] = float64(r+1) * float64(freqOfFreq[r+1]) / float64(freqOfFreq[r])
+		}
+		seenMass += counts[bigram]
+	}
+
+	var unseen []string
+	for _, bigram := range possibleBigrams(alphabet) {
+		if _, ok := counts[bigram]; !ok {
+			unseen = append(unseen, bigram)
+		}
+	}
+
+	// Reserve N(1) for unseen bigrams and rescale seen ones to keep the total
+	unseenMass := 0.0
+	if len(unseen) > 0 {
+		unseenMass = float64(freqOfFreq[1])
+	}
+	if seenMass > 0 {
+		scale := (total - unseenMass) / seenMass
+		for bigram := range counts {
+			counts[bigram] *= scale
+		}
+	}
+	for _, bigram := range unseen {
+		counts[bigram] = unseenMass / float64(len(unseen))
+	}
+}
+
+func (goodTuringSmoother) String() string { return "gt" }
+
+// interpSmoother interpolates each bigram's relative frequency with the
+// product of its characters' frequencies, p = λ·c(xy)/N + (1-λ)·p(x)·p(y),
+// and scales the result back to counts. Character frequencies are add-one
+// smoothed, so that alphabet characters missing from the table still get a
+// backoff.
+type interpSmoother float64
+
+func (lambda interpSmoother) Smooth(counts map[string]float64, alphabet []rune) {
+	// Character frequencies from the first character of each bigram; every
+	// character, including the boundary marker, starts exactly one bigram
+	unigrams := make(map[rune]float64)
+	total := 0.0
+	for bigram, c := range counts {
+		if runes := []rune(bigram); len(runes) == 2 {
+			unigrams[runes[0]] += c
+		}
+		total += c
+	}
+	if total == 0 {
+		return
+	}
+
+	for _, bigram := range possibleBigrams(alphabet) {
+		if _, ok := counts[bigram]; !ok {
+			counts[bigram] = 0
+		}
+	}
+
+	// Add one to the count of every character of the alphabet, the boundary
+	// marker and the table
+	characters := map[rune]bool{'_': true}
+	for _, r := range alphabet {
+		characters[r] = true
+	}
+	for r := range unigrams {
+		characters[r] = true
+	}
+	unigramTotal := total + float64(len(characters))
+	p := func(r rune) float64 { return (unigrams[r] + 1) / unigramTotal }
+
+	l := float64(lambda)
+	for bigram, c := range counts {
+		runes := []rune(bigram)
+		if len(runes) != 2 {
+			continue
+		}
+		counts[bigram] = (l*c/total + (1-l)*p(runes[0])*p(runes[1])) * total
+	}
+}
+
+func (lambda interpSmoother) String() string {
+	return "interp:" + strconv.FormatFloat(float64(lambda), 'g', -1, 64)
+}
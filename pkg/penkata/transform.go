@@ -0,0 +1,432 @@
+package penkata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WeightTransform maps bigram counts to the weights used for scoring.
+// Transforms are parsed from specifications such as "log1p", "pow:0.5" or
+// "clip:1000+normal" (apply clip:1000, then normal).
+type WeightTransform interface {
+	// Apply transforms the weights in place. The table the weights were
+	// derived from provides corpus statistics such as document frequencies.
+	Apply(weights map[string]float64, table *BigramTable) error
+	// String returns the specification of the transform
+	String() string
+}
+
+// TransformFactory builds a transform from the colon-separated arguments that
+// follow its name in a specification
+type TransformFactory func(args []string) (WeightTransform, error)
+
+// transformSeparator joins the steps of a composed transform specification
+const transformSeparator = "+"
+
+// transforms is the registry of named transform factories
+var transforms = map[string]TransformFactory{}
+
+// Built-in transforms, available without parsing
+var (
+	// Raw keeps the original count values
+	Raw = NewTransform("raw", func(map[string]float64) {})
+	// Log1p applies log1p to reduce impact of common bigrams
+	Log1p = NewTransform("log1p", func(weights map[string]float64) {
+		for bigram, w := range weights {
+			weights[bigram] = math.Log1p(w)
+		}
+	})
+	// Normal converts weights to probabilities by dividing each by the sum of
+	// all weights, rather than by the table's total count, so that it also
+	// normalizes the output of earlier steps (e.g. clip:1000+normal)
+	Normal = NewTransform("normal", func(weights map[string]float64) {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+		for bigram, w := range weights {
+			weights[bigram] = w / total
+		}
+	})
+)
+
+func init() {
+	for _, t := range []WeightTransform{Raw, Log1p, Normal} {
+		RegisterTransform(t.String(), fixedTransform(t))
+	}
+	RegisterTransform("pow", powTransform)
+	RegisterTransform("inv", invTransform)
+	RegisterTransform("zipf", zipfTransform)
+	RegisterTransform("pct", pctTransform)
+	RegisterTransform("clip", clipTransform)
+	RegisterTransform("top", topTransform)
+	RegisterTransform("df", dfTransform)
+	RegisterTransform("idf", idfTransform)
+	RegisterTransform("tfidf", tfidfTransform)
+	RegisterTransform("logodds", logOddsTransform)
+	RegisterTransform("stroke", strokeTransform)
+	RegisterTransform("kbd", kbdTransform)
+}
+
+// RegisterTransform makes a transform available to ParseTransform under the given name
+func RegisterTransform(name string, factory TransformFactory) {
+	transforms[name] = factory
+}
+
+// TransformNames returns the names of all registered transforms in sorted order
+func TransformNames() []string {
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseTransform parses a transform specification. Each step is a registered
+// name optionally followed by colon-separated arguments; steps joined with "+"
+// are applied from left to right.
+func ParseTransform(spec string) (WeightTransform, error) {
+	var steps []WeightTransform
+	for _, step := range strings.Split(spec, transformSeparator) {
+		parts := strings.Split(step, ":")
+		factory, ok := transforms[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weight transform %q (available: %s)",
+				parts[0], strings.Join(TransformNames(), ", "))
+		}
+		t, err := factory(parts[1:])
+		if err != nil {
+			return nil, fmt.Errorf("weight transform %q: %w", step, err)
+		}
+		steps = append(steps, t)
+	}
+	if len(steps) == 1 {
+		return steps[0], nil
+	}
+	return composedTransform(steps), nil
+}
+
+// referenceTransform is implemented by transforms that compare against other
+// count tables, which are read by the caller with its own load options
+type referenceTransform interface {
+	references() []string
+	setReference(path string, table *BigramTable)
+}
+
+// TransformReferences returns the paths of the reference tables a transform
+// compares against. Each must be read and passed to SetTransformReference
+// before the transform is applied.
+func TransformReferences(t WeightTransform) []string {
+	switch t := t.(type) {
+	case composedTransform:
+		var paths []string
+		for _, step := range t {
+			paths = append(paths, TransformReferences(step)...)
+		}
+		return paths
+	case referenceTransform:
+		return t.references()
+	}
+	return nil
+}
+
+// SetTransformReference provides the reference table read from path to the
+// steps of a transform that compare against it
+func SetTransformReference(t WeightTransform, path string, table *BigramTable) {
+	switch t := t.(type) {
+	case composedTransform:
+		for _, step := range t {
+			SetTransformReference(step, path, table)
+		}
+	case referenceTransform:
+		t.setReference(path, table)
+	}
+}
+
+// funcTransform is a transform implemented by a function
+type funcTransform struct {
+	spec  string
+	apply func(map[string]float64, *BigramTable) error
+}
+
+// NewTransform creates a transform from a specification and a function that
+// transforms weights in place
+func NewTransform(spec string, apply func(weights map[string]float64)) WeightTransform {
+	return NewTableTransform(spec, func(weights map[string]float64, _ *BigramTable) error {
+		apply(weights)
+		return nil
+	})
+}
+
+// NewTableTransform creates a transform whose function also uses the table the
+// weights were derived from
+func NewTableTransform(spec string, apply func(weights map[string]float64, table *BigramTable) error) WeightTransform {
+	return &funcTransform{spec: spec, apply: apply}
+}
+
+func (t *funcTransform) Apply(weights map[string]float64, table *BigramTable) error {
+	return t.apply(weights, table)
+}
+
+func (t *funcTransform) String() string { return t.spec }
+
+// composedTransform applies several transforms in order
+type composedTransform []WeightTransform
+
+func (c composedTransform) Apply(weights map[string]float64, table *BigramTable) error {
+	for _, t := range c {
+		if err := t.Apply(weights, table); err != nil {
+			return fmt.Errorf("%s: %w", t, err)
+		}
+	}
+	return nil
+}
+
+func (c composedTransform) String() string {
+	specs := make([]string, len(c))
+	for i, t := range c {
+		specs[i] = t.String()
+	}
+	return strings.Join(specs, transformSeparator)
+}
+
+// fixedTransform returns a factory for a transform that takes no arguments
+func fixedTransform(t WeightTransform) TransformFactory {
+	return func(args []string) (WeightTransform, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("takes no arguments")
+		}
+		return t, nil
+	}
+}
+
+// parseFloatArgs parses between min and max numeric transform arguments
+func parseFloatArgs(args []string, min, max int) ([]float64, error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return nil, fmt.Errorf("expected %d argument(s), got %d", min, len(args))
+		}
+		return nil, fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(args))
+	}
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %q", arg)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// checkExponent reports an error if an exponent is negative or not a number
+func checkExponent(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+		return fmt.Errorf("exponent must be a non-negative number, got %g", v)
+	}
+	return nil
+}
+
+// transformSpec formats a transform name and its arguments as a specification
+func transformSpec(name string, args ...float64) string {
+	parts := []string{name}
+	for _, arg := range args {
+		parts = append(parts, strconv.FormatFloat(arg, 'g', -1, 64))
+	}
+	return strings.Join(parts, ":")
+}
+
+// powTransform raises weights to a power: pow:α gives count^α
+func powTransform(args []string) (WeightTransform, error) {
+	v, err := parseFloatArgs(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	alpha := v[0]
+	if err := checkExponent(alpha); err != nil {
+		return nil, err
+	}
+	return NewTransform(transformSpec("pow", alpha), func(weights map[string]float64) {
+		for bigram, w := range weights {
+			if w > 0 {
+				weights[bigram] = math.Pow(w, alpha)
+			}
+		}
+	}), nil
+}
+
+// invTransform emphasizes rare bigrams: inv[:α] gives count^-α (default α=1)
+func invTransform(args []string) (WeightTransform, error) {
+	v, err := parseFloatArgs(args, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	alpha := 1.0
+	if len(v) == 1 {
+		alpha = v[0]
+	}
+	if err := checkExponent(alpha); err != nil {
+		return nil, err
+	}
+	spec := transformSpec("inv", v...)
+	return NewTransform(spec, func(weights map[string]float64) {
+		for bigram, w := range weights {
+			if w > 0 {
+				weights[bigram] = math.Pow(w, -alpha)
+			}
+		}
+	}), nil
+}
+
+// zipfTransform replaces weights by their Zipf rank: zipf[:s] gives 1/rank^s
+// (default s=1), where the most frequent bigram has rank 1
+func zipfTransform(args []string) (WeightTransform, error) {
+	v, err := parseFloatArgs(args, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	s := 1.0
+	if len(v) == 1 {
+		s = v[0]
+	}
+	if err := checkExponent(s); err != nil {
+		return nil, err
+	}
+	return NewTransform(transformSpec("zipf", v...), func(weights map[string]float64) {
+		for i, bigram := range rankBigrams(weights) {
+			weights[bigram] = math.Pow(float64(i+1), -s)
+		}
+	}), nil
+}
+
+// pctTransform replaces weights by their percentile: the fraction of bigrams
+// whose weight is at most this one's, in (0, 1]. Tied bigrams share the
+// percentile of the highest rank in their group.
+func pctTransform(args []string) (WeightTransform, error) {
+	if _, err := parseFloatArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return NewTransform("pct", func(weights map[string]float64) {
+		ranked := rankBigrams(weights)
+		n := float64(len(ranked))
+		first := 0 // Position of the first bigram tied with the current one
+		pct := make([]float64, len(ranked))
+		for i, bigram := range ranked {
+			if weights[bigram] != weights[ranked[first]] {
+				first = i
+			}
+			pct[i] = (n - float64(first)) / n
+		}
+		for i, bigram := range ranked {
+			weights[bigram] = pct[i]
+		}
+	}), nil
+}
+
+// clipTransform caps weights: clip:max limits every weight to at most max
+func clipTransform(args []string) (WeightTransform, error) {
+	v, err := parseFloatArgs(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	limit := v[0]
+	return NewTransform(transformSpec("clip", limit), func(weights map[string]float64) {
+		for bigram, w := range weights {
+			weights[bigram] = math.Min(w, limit)
+		}
+	}), nil
+}
+
+// topTransform keeps only the K highest weights: top:K drops all other bigrams
+func topTransform(args []string) (WeightTransform, error) {
+	v, err := parseFloatArgs(args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	k := int(v[0])
+	if k < 1 || float64(k) != v[0] {
+		return nil, fmt.Errorf("K must be a positive integer")
+	}
+	return NewTransform(transformSpec("top", v[0]), func(weights map[string]float64) {
+		ranked := rankBigrams(weights)
+		for _, bigram := range ranked[min(k, len(ranked)):] {
+			delete(weights, bigram)
+		}
+	}), nil
+}
+
+// dfTransform replaces weights by document frequency: the fraction of files
+// that contain the bigram, so that bigrams repeated by only a few large files
+// count for less than bigrams that are common everywhere
+func dfTransform(args []string) (WeightTransform, error) {
+	if _, err := parseFloatArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return NewTableTransform("df", func(weights map[string]float64, table *BigramTable) error {
+		n, err := table.Documents()
+		if err != nil {
+			return err
+		}
+		for bigram := range weights {
+			weights[bigram] = float64(table.DocFreq[bigram]) / float64(n)
+		}
+		return nil
+	}), nil
+}
+
+// idfTransform replaces weights by smoothed inverse document frequency,
+// log((1+N)/(1+df)) + 1 for a corpus of N files
+func idfTransform(args []string) (WeightTransform, error) {
+	if _, err := parseFloatArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return NewTableTransform("idf", func(weights map[string]float64, table *BigramTable) error {
+		return applyIDF(weights, table, func(_, idf float64) float64 { return idf })
+	}), nil
+}
+
+// tfidfTransform multiplies weights by smoothed inverse document frequency.
+// Compose it after another transform to dampen the term frequency, e.g.
+// log1p+tfidf.
+func tfidfTransform(args []string) (WeightTransform, error) {
+	if _, err := parseFloatArgs(args, 0, 0); err != nil {
+		return nil, err
+	}
+	return NewTableTransform("tfidf", func(weights map[string]float64, table *BigramTable) error {
+		return applyIDF(weights, table, func(w, idf float64) float64 { return w * idf })
+	}), nil
+}
+
+// applyIDF combines each weight with its bigram's smoothed inverse document frequency
+func applyIDF(weights map[string]float64, table *BigramTable, combine func(w, idf float64) float64) error {
+	n, err := table.Documents()
+	if err != nil {
+		return err
+	}
+	for bigram, w := range weights {
+		idf := math.Log(float64(1+n)/float64(1+table.DocFreq[bigram])) + 1
+		weights[bigram] = combine(w, idf)
+	}
+	return nil
+}
+
+// rankBigrams returns bigrams ordered by descending weight, with ties broken
+// alphabetically so that ranks are deterministic
+func rankBigrams(weights map[string]float64) []string {
+	ranked := make([]string, 0, len(weights))
+	for bigram := range weights {
+		ranked = append(ranked, bigram)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		wi, wj := weights[ranked[i]], weights[ranked[j]]
+		if wi != wj {
+			return wi > wj
+		}
+		return ranked[i] < ranked[j]
+	})
+	return ranked
+}
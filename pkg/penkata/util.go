@@ -64,6 +64,42 @@ func collapseCommonApostraphes(w []rune) []rune {
 	return w
 }
 
+// normalizationName identifies the word maps applied by normalizeWord. It is
+// recorded in count table headers, so change it whenever the maps change.
+const normalizationName = "punct-apostrophe/v1"
+
+// Normalizer describes how words are normalized before bigram extraction.
+// The zero value preserves case.
+type Normalizer struct {
+	FoldCase bool // Lowercase words before extracting bigrams
+}
+
+// Name returns the identifier of the word normalization rules.
+func (n Normalizer) Name() string {
+	return normalizationName
+}
+
+// CaseMode returns "fold" if words are lowercased, or "preserve" otherwise.
+func (n Normalizer) CaseMode() string {
+	if n.FoldCase {
+		return "fold"
+	}
+	return "preserve"
+}
+
+// Bigrams returns all bigrams from a word normalized according to n.
+func (n Normalizer) Bigrams(word string) []string {
+	return wordBigrams(n.Normalize(word))
+}
+
+// Normalize returns the form of a word that its bigrams are extracted from.
+func (n Normalizer) Normalize(word string) string {
+	if n.FoldCase {
+		word = strings.ToLower(word)
+	}
+	return normalizeWord(word)
+}
+
 func normalizeWord(w string) string {
 	wordMaps := make([](func([]rune) []rune), 0)
 	wordMaps = append(
@@ -84,7 +120,12 @@ func normalizeWord(w string) string {
 
 // extractBigramsFromWord returns all bigrams from a normalized word
 func extractBigramsFromWord(word string) []string {
-	normalized := normalizeWord(word)
+	return wordBigrams(normalizeWord(word))
+}
+
+// wordBigrams returns all bigrams from a word that is already normalized,
+// including the leading and trailing word boundary bigrams
+func wordBigrams(normalized string) []string {
 	runes := []rune(normalized)
 
 	if len(runes) == 0 {
@@ -6,9 +6,10 @@ import "strings"
 // Window represents a section of text with metadata.
 // It maintains both the original words and their derived bigrams for scoring.
 type Window struct {
-	words   []string       // Raw words, not normalized
-	bigrams map[string]int // Map of bigrams to their counts for scoring
-	params  *WindowParams  // Configuration parameters for this window
+	words    []string                          // Raw words, not normalized
+	bigrams  map[string]int                    // Map of bigrams to their counts for scoring
+	features map[FeatureCounter]map[string]int // Feature counts of the scorers that are FeatureCounters
+	params   *WindowParams                     // Configuration parameters for this window
 }
 
 // NewWindow creates a new empty window with the provided parameters.
@@ -25,7 +26,8 @@ func (w *Window) Text() string {
 	return strings.Join(w.words, " ")
 }
 
-// Score calculates the window score based on weighted unique bigrams.
+// Score calculates the window score based on weighted unique bigrams, or the
+// base scorer of the window's parameters, adjusted by their scorers.
 //
 // NOTE: Due to floating-point arithmetic not being associative (a+(b+c) ≠ (a+b)+c),
 // and Go's non-deterministic map iteration order, this function may return slightly
@@ -37,6 +39,9 @@ func (w *Window) Text() string {
 //  2. Implementing fixed-precision decimal arithmetic
 //  3. Using epsilon comparisons for score equality checks
 func (w *Window) Score() float64 {
+	if w.params != nil && w.params.Base != nil {
+		return w.applyScorers(w.params.Base.BaseScore(w))
+	}
 	score := 0.0
 	for bigram, count := range w.bigrams {
 		if count > 0 {
@@ -45,6 +50,17 @@ func (w *Window) Score() float64 {
 			}
 		}
 	}
+	return w.applyScorers(score)
+}
+
+// applyScorers adjusts a score by the scorers of the window's parameters
+func (w *Window) applyScorers(score float64) float64 {
+	if w.params == nil {
+		return score
+	}
+	for _, scorer := range w.params.Scorers {
+		score = scorer.Score(w, score)
+	}
 	return score
 }
 
@@ -60,7 +76,7 @@ func (w *Window) pushWord(word string) {
 	}
 
 	// Get all bigrams from this word
-	bigrams := extractBigramsFromWord(word)
+	bigrams := w.extractBigrams(word)
 	if len(bigrams) == 0 {
 		return
 	}
@@ -69,6 +85,55 @@ func (w *Window) pushWord(word string) {
 	for _, bg := range bigrams {
 		w.bigrams[bg]++
 	}
+	w.countFeatures(word, bigrams, 1)
+}
+
+// countFeatures adds delta to the counts of the word's features for the base
+// scorer and each scorer that is a FeatureCounter
+func (w *Window) countFeatures(word string, bigrams []string, delta int) {
+	if w.params == nil {
+		return
+	}
+	if counter, ok := w.params.Base.(FeatureCounter); ok {
+		w.addFeatures(counter, word, bigrams, delta)
+	}
+	for _, scorer := range w.params.Scorers {
+		if counter, ok := scorer.(FeatureCounter); ok {
+			w.addFeatures(counter, word, bigrams, delta)
+		}
+	}
+}
+
+// addFeatures adds delta to the counts of the word's features for a counter
+func (w *Window) addFeatures(counter FeatureCounter, word string, bigrams []string, delta int) {
+	if w.features == nil {
+		w.features = make(map[FeatureCounter]map[string]int)
+	}
+	counts := w.features[counter]
+	if counts == nil {
+		counts = make(map[string]int)
+		w.features[counter] = counts
+	}
+	for _, feature := range counter.Features(word, bigrams) {
+		counts[feature] += delta
+		if counts[feature] == 0 {
+			delete(counts, feature)
+		}
+	}
+}
+
+// FeatureCount returns how often a feature of the counter occurs in the window
+func (w *Window) FeatureCount(counter FeatureCounter, feature string) int {
+	return w.features[counter][feature]
+}
+
+// extractBigrams returns the bigrams of a word, normalized the same way as the
+// counts behind the window's weights.
+func (w *Window) extractBigrams(word string) []string {
+	if w.params == nil || w.params.Weights == nil {
+		return extractBigramsFromWord(word)
+	}
+	return w.params.Weights.Normalizer.Bigrams(word)
 }
 
 // shiftWord removes the first word from the window and updates the bigram counts accordingly.
@@ -93,7 +158,7 @@ func (w *Window) shiftWord() string {
 	}
 
 	// Get all bigrams from the removed word
-	bigrams := extractBigramsFromWord(word)
+	bigrams := w.extractBigrams(word)
 	if len(bigrams) == 0 {
 		return word
 	}
@@ -107,6 +172,7 @@ func (w *Window) shiftWord() string {
 			delete(w.bigrams, bg)
 		}
 	}
+	w.countFeatures(word, bigrams, -1)
 
 	return word
 }
@@ -127,6 +193,17 @@ func (w *Window) Clone() Window {
 		newWindow.bigrams[bg] = count
 	}
 
+	// Copy feature counts
+	if w.features != nil {
+		newWindow.features = make(map[FeatureCounter]map[string]int, len(w.features))
+		for counter, counts := range w.features {
+			newWindow.features[counter] = make(map[string]int, len(counts))
+			for feature, count := range counts {
+				newWindow.features[counter][feature] = count
+			}
+		}
+	}
+
 	return newWindow
 }
 
@@ -4,15 +4,53 @@ import (
 	"fmt"
 )
 
+// Reporter reports features of a window in output columns
+type Reporter interface {
+	// Columns returns the names of the output columns reported
+	Columns() []string
+	// Report returns the values of the output columns for a window
+	Report(w *Window) []string
+}
+
+// Scorer adjusts the bigram score of a window, e.g. to reward coverage of
+// letterform features, and reports what it found for output
+type Scorer interface {
+	// Score returns the window's adjusted score given the score so far
+	Score(w *Window, score float64) float64
+	Reporter
+}
+
+// BaseScorer scores windows in place of the sum of their bigram weights, e.g.
+// for legibility drills, and reports what it found like a Scorer
+type BaseScorer interface {
+	// BaseScore returns the window's score before the scorers are applied
+	BaseScore(w *Window) float64
+	Reporter
+}
+
+// FeatureCounter is implemented by scorers that score windows by features of
+// their words, such as join types. The window counts the features of each word
+// as it enters and leaves, so scoring only reads the counts (see
+// Window.FeatureCount).
+type FeatureCounter interface {
+	// Features returns the features of a word, given its normalized bigrams.
+	// A feature may be returned more than once.
+	Features(word string, bigrams []string) []string
+}
+
 // WindowParams holds configuration parameters for window processing
 type WindowParams struct {
-	ID       string // Unique identifier
-	Weights  *BigramWeights
-	MaxChars int
+	ID        string // Unique identifier
+	Label     string // Name of the weights' source for output, or empty
+	Weights   *BigramWeights
+	MaxChars  int
+	Base      BaseScorer // Replaces the sum of bigram weights, or nil
+	Scorers   []Scorer   // Applied in order after summing bigram weights
+	Reporters []Reporter // Report output columns without changing the score
 }
 
 // NewWindowParams creates a new parameter set
-func NewWindowParams(weights *BigramWeights, maxChars int) *WindowParams {
+func NewWindowParams(weights *BigramWeights, maxChars int, scorers ...Scorer) *WindowParams {
 	// Use the maxChars value as the ID for simpler identification
 	id := fmt.Sprintf("%d", maxChars)
 
@@ -20,5 +58,6 @@ func NewWindowParams(weights *BigramWeights, maxChars int) *WindowParams {
 		ID:       id,
 		Weights:  weights,
 		MaxChars: maxChars,
+		Scorers:  scorers,
 	}
 }